@@ -0,0 +1,101 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/homeport/go-cache-prog/pkg/provider/cos"
+	"github.com/homeport/go-cache-prog/pkg/provider/s3"
+	"github.com/spf13/cobra"
+)
+
+type migrator interface {
+	SetLogOutput(w io.Writer)
+	Migrate(ctx context.Context, workers int) (int, error)
+	Close(ctx context.Context) error
+}
+
+var cosMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the COS bucket to the content-addressed layout",
+	Long: `Migrate the COS bucket to the content-addressed layout
+
+Action objects written by previous versions contain the content themselves,
+they are converted into action records that refer to content-addressed
+objects. The content is copied within the bucket. Previous versions read
+action records as empty content, so only migrate once all clients are
+updated and use --layout object.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider, err := cos.NewProvider(cosCmdSettings.config)
+		if err != nil {
+			return err
+		}
+
+		return runMigrate(cmd.Context(), provider)
+	},
+}
+
+var s3MigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the S3 bucket to the content-addressed layout",
+	Long: `Migrate the S3 bucket to the content-addressed layout
+
+Action objects written by previous versions contain the content themselves,
+they are converted into action records that refer to content-addressed
+objects. The content is copied within the bucket. Previous versions read
+action records as empty content, so only migrate once all clients are
+updated and use --layout object.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider, err := s3.NewProvider(s3CmdSettings.config)
+		if err != nil {
+			return err
+		}
+
+		return runMigrate(cmd.Context(), provider)
+	},
+}
+
+func runMigrate(ctx context.Context, provider migrator) error {
+	provider.SetLogOutput(os.Stderr)
+
+	migrated, err := provider.Migrate(ctx, rootCmdSettings.workers)
+	fmt.Printf("Migrated %d action objects\n", migrated)
+	if err != nil {
+		return err
+	}
+
+	return provider.Close(ctx)
+}
+
+func init() {
+	cosCmd.AddCommand(cosMigrateCmd)
+	s3Cmd.AddCommand(s3MigrateCmd)
+}
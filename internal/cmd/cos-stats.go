@@ -65,7 +65,7 @@ var cosStatsCmd = &cobra.Command{
 				WithMaxRetries(cosCmdSettings.config.Cos.MaxRetries),
 		)
 
-		var objectCount int
+		var objectCount, recordCount int
 
 		var min int64 = math.MaxInt64
 		var max int64
@@ -73,9 +73,20 @@ var cosStatsCmd = &cobra.Command{
 		var oldest = time.Now()
 
 		var pageFunc = func(listObjectOutput *s3.ListObjectsOutput, _ bool) bool {
-			objectCount += len(listObjectOutput.Contents)
-
 			for _, object := range listObjectOutput.Contents {
+				// Only objects with content are counted, action records of the
+				// content-addressed layout are empty and refer to an object,
+				// manifests are not part of the cache
+				switch key := aws.StringValue(object.Key); {
+				case strings.HasPrefix(key, "action/") && aws.Int64Value(object.Size) == 0:
+					recordCount++
+					continue
+
+				case !strings.HasPrefix(key, "action/") && !strings.HasPrefix(key, "object/"):
+					continue
+				}
+
+				objectCount++
 				if object.LastModified != nil {
 					if object.LastModified.Before(oldest) {
 						oldest = *object.LastModified
@@ -108,6 +119,7 @@ var cosStatsCmd = &cobra.Command{
 		fmt.Printf("Largest object size: %s\n", humanReadableSize(max))
 
 		fmt.Printf("Total object count: %d\n", objectCount)
+		fmt.Printf("Action record count: %d\n", recordCount)
 
 		return nil
 	},
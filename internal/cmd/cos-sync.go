@@ -0,0 +1,92 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/homeport/go-cache-prog/pkg/provider/cos"
+	"github.com/homeport/go-cache-prog/pkg/provider/s3"
+	"github.com/spf13/cobra"
+)
+
+type syncer interface {
+	SetLogOutput(w io.Writer)
+	ReplayJournal() int
+	Close(ctx context.Context) error
+}
+
+var cosSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Upload pending uploads of previous runs to the COS bucket",
+	Long: `Upload pending uploads of previous runs to the COS bucket
+
+Uploads that did not finish, e.g. because the process was killed, are
+recorded in the journal of the local cache directory. They are uploaded by
+the next run automatically, or explicitly using this command.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider, err := cos.NewProvider(cosCmdSettings.config)
+		if err != nil {
+			return err
+		}
+
+		return runSync(cmd.Context(), provider)
+	},
+}
+
+var s3SyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Upload pending uploads of previous runs to the S3 bucket",
+	Long: `Upload pending uploads of previous runs to the S3 bucket
+
+Uploads that did not finish, e.g. because the process was killed, are
+recorded in the journal of the local cache directory. They are uploaded by
+the next run automatically, or explicitly using this command.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider, err := s3.NewProvider(s3CmdSettings.config)
+		if err != nil {
+			return err
+		}
+
+		return runSync(cmd.Context(), provider)
+	},
+}
+
+func runSync(ctx context.Context, provider syncer) error {
+	provider.SetLogOutput(os.Stderr)
+
+	fmt.Printf("Uploading %d pending uploads\n", provider.ReplayJournal())
+	return provider.Close(ctx)
+}
+
+func init() {
+	cosCmd.AddCommand(cosSyncCmd)
+	s3Cmd.AddCommand(s3SyncCmd)
+}
@@ -0,0 +1,114 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/homeport/go-cache-prog/pkg/provider/cos"
+	"github.com/homeport/go-cache-prog/pkg/provider/s3"
+	"github.com/spf13/cobra"
+)
+
+type warmer interface {
+	SetLogOutput(w io.Writer)
+	Warm(ctx context.Context, options cos.WarmOptions) (cos.WarmResult, error)
+	Close(ctx context.Context) error
+}
+
+var warmCmdSettings cos.WarmOptions
+
+var cosWarmCmd = &cobra.Command{
+	Use:   "warm",
+	Short: "Download entries of the COS bucket into the local cache directory",
+	Long: `Download entries of the COS bucket into the local cache directory
+
+The most recently uploaded entries of the bucket, or the entries of the
+manifest, are downloaded concurrently into the local cache directory, e.g.
+to populate the cache of a fresh CI container before the build starts.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider, err := cos.NewProvider(cosCmdSettings.config)
+		if err != nil {
+			return err
+		}
+
+		return runWarm(cmd.Context(), provider)
+	},
+}
+
+var s3WarmCmd = &cobra.Command{
+	Use:   "warm",
+	Short: "Download entries of the S3 bucket into the local cache directory",
+	Long: `Download entries of the S3 bucket into the local cache directory
+
+The most recently uploaded entries of the bucket, or the entries of the
+manifest, are downloaded concurrently into the local cache directory, e.g.
+to populate the cache of a fresh CI container before the build starts.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider, err := s3.NewProvider(s3CmdSettings.config)
+		if err != nil {
+			return err
+		}
+
+		return runWarm(cmd.Context(), provider)
+	},
+}
+
+func runWarm(ctx context.Context, provider warmer) error {
+	provider.SetLogOutput(os.Stderr)
+
+	options := warmCmdSettings
+	options.Workers = rootCmdSettings.workers
+
+	result, err := provider.Warm(ctx, options)
+	fmt.Printf("Downloaded %d entries (%s) in %v, %d already cached, %d missing, %d skipped, %d failed\n",
+		result.Downloaded, humanReadableSize(result.Bytes), result.Duration.Round(time.Millisecond), result.Cached, result.Missing, result.Skipped, result.Failed)
+	if err != nil {
+		return err
+	}
+
+	return provider.Close(ctx)
+}
+
+func addWarmFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&warmCmdSettings.Prefix, "prefix", "", "prefix of the action ids of the entries")
+	cmd.Flags().DurationVar(&warmCmdSettings.Since, "since", 0, "download entries uploaded within the duration, e.g. 24h (default all entries)")
+	cmd.Flags().IntVar(&warmCmdSettings.Limit, "limit", 0, "maximum number of entries, the most recently uploaded first (default no limit)")
+	cmd.Flags().BoolVar(&warmCmdSettings.Manifest, "from-manifest", false, "download the entries of the manifest (see --manifest-file and --manifest-key)")
+}
+
+func init() {
+	addWarmFlags(cosWarmCmd)
+	addWarmFlags(s3WarmCmd)
+
+	cosCmd.AddCommand(cosWarmCmd)
+	s3Cmd.AddCommand(s3WarmCmd)
+}
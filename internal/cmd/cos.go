@@ -25,14 +25,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/homeport/go-cache-prog/pkg/cache"
 	"github.com/homeport/go-cache-prog/pkg/provider/cos"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 type cosCmdOpts struct {
-	config cos.Config
+	config            cos.Config
+	signingKeyFile    string
+	encryptionKeyFile string
 }
 
 var cosCmdSettings cosCmdOpts
@@ -45,12 +49,20 @@ var cosCmd = &cobra.Command{
 	SilenceErrors: true,
 
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := readSecretFile(cosCmdSettings.signingKeyFile, &cosCmdSettings.config.SigningKey); err != nil {
+			return err
+		}
+
+		if err := readSecretFile(cosCmdSettings.encryptionKeyFile, &cosCmdSettings.config.EncryptionKey); err != nil {
+			return err
+		}
+
 		provider, err := cos.NewProvider(cosCmdSettings.config)
 		if err != nil {
 			return err
 		}
 
-		handler := cache.New(os.Stdin, os.Stdout, provider).WithConcurrentWorkers(rootCmdSettings.workers)
+		handler := cache.NewWithContextProvider(os.Stdin, os.Stdout, provider).WithConcurrentWorkers(rootCmdSettings.workers)
 
 		if rootCmdSettings.logfile != "" {
 			file, err := os.OpenFile(rootCmdSettings.logfile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
@@ -60,6 +72,7 @@ var cosCmd = &cobra.Command{
 			defer func() { _ = file.Close() }()
 
 			handler.WithLogOutput(file)
+			provider.WithLogOutput(file)
 		}
 
 		return handler.Run(cmd.Context())
@@ -70,7 +83,9 @@ func init() {
 	rootCmd.AddCommand(cosCmd)
 	cosCmd.Flags().SortFlags = false
 
-	cosCmd.PersistentFlags().StringVar(&cosCmdSettings.config.CacheDir, "cache-dir", filepath.Join(os.TempDir(), "go-cache"), "location of the local cache directory")
+	addOptionsFlags(cosCmd.PersistentFlags(), &cosCmdSettings.config.Options)
+	cosCmd.PersistentFlags().StringVar(&cosCmdSettings.signingKeyFile, "signing-key-file", "", "file with the secret used to sign uploads and verify downloads")
+	cosCmd.PersistentFlags().StringVar(&cosCmdSettings.encryptionKeyFile, "encryption-key-file", "", "file with the hex or base64 encoded 256 bit key used to encrypt uploads")
 
 	cosCmd.PersistentFlags().StringVar(&cosCmdSettings.config.Cos.Endpoint, "endpoint", "", "specify URL endpoint of the COS instance")
 	cosCmd.PersistentFlags().StringVar(&cosCmdSettings.config.Cos.Region, "region", "", "specify region of the COS instance")
@@ -79,11 +94,44 @@ func init() {
 	cosCmd.PersistentFlags().StringVar(&cosCmdSettings.config.Cos.Bucket, "bucket", "", "specify bucket to be used")
 
 	mapOsEnvToConfig("GO_CACHE_PROG_COS_CONFIG", &cosCmdSettings.config)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_SIGNING_KEY", &cosCmdSettings.config.SigningKey)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_ENCRYPTION_KEY", &cosCmdSettings.config.EncryptionKey)
 	mapOsEnvToVarIfSet("GO_CACHE_PROG_COS_ENDPOINT", &cosCmdSettings.config.Cos.Endpoint)
 	mapOsEnvToVarIfSet("GO_CACHE_PROG_COS_REGION", &cosCmdSettings.config.Cos.Region)
 	mapOsEnvToVarIfSet("GO_CACHE_PROG_COS_BUCKET", &cosCmdSettings.config.Cos.Bucket)
 	mapOsEnvToVarIfSet("GO_CACHE_PROG_COS_ACCESSKEYID", &cosCmdSettings.config.Cos.AccessKeyID)
 	mapOsEnvToVarIfSet("GO_CACHE_PROG_COS_SECRETACCESSKEY", &cosCmdSettings.config.Cos.SecretAccessKey)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_COS_READONLY_ACCESSKEYID", &cosCmdSettings.config.Cos.ReadOnlyAccessKeyID)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_COS_READONLY_SECRETACCESSKEY", &cosCmdSettings.config.Cos.ReadOnlySecretAccessKey)
+}
+
+// addOptionsFlags adds the flags of the options that are shared by the
+// providers using the local cache directory and the remote layout of COS
+func addOptionsFlags(flags *pflag.FlagSet, options *cos.Options) {
+	flags.StringVar(&options.CacheDir, "cache-dir", filepath.Join(os.TempDir(), "go-cache"), "location of the local cache directory")
+	flags.BoolVar(&options.Fsync, "fsync", false, "sync local cache files to stable storage before making them visible")
+	flags.BoolVar(&options.VerifyPuts, "verify-puts", false, "verify that stored content matches its output id")
+	flags.BoolVar(&options.SkipDownloadVerify, "skip-download-verify", false, "skip verification that downloaded content matches its output id")
+	flags.Var(newSizeValue(&options.MaxSize), "max-size", "maximum size of the local cache directory, e.g. 10GiB (default no limit)")
+	flags.DurationVar(&options.MaxAge, "max-age", 0, "maximum time an unused entry is kept in the local cache directory (default no limit)")
+	flags.BoolVar(&options.ReadOnly, "read-only", false, "store entries in the local cache directory only and never write to the remote cache")
+	flags.StringVar(&options.Compression, "compression", cos.CompressionNone, "compression of uploads (none or gzip)")
+	flags.IntVar(&options.CompressionLevel, "compression-level", 0, "compression level from 1 (fastest) to 9 (smallest) (default level of the compression)")
+	flags.StringVar(&options.Layout, "layout", cos.LayoutAction, "layout of uploads (action for the layout of previous versions, or object for content-addressed objects once all clients are updated)")
+	flags.IntVar(&options.UploadConcurrency, "upload-concurrency", cos.DefaultUploadConcurrency, "maximum number of concurrent uploads")
+	flags.IntVar(&options.UploadBacklog, "upload-backlog", cos.DefaultUploadBacklog, "maximum number of uploads waiting to be uploaded")
+	flags.IntVar(&options.UploadRetries, "upload-retries", cos.DefaultUploadRetries, "number of retries of uploads that failed with a transient error, negative to disable")
+	flags.StringVar(&options.UploadDropPolicy, "upload-drop-policy", cos.DropNewest, "upload to drop when the backlog is full (newest, oldest, or none to wait)")
+	flags.IntVar(&options.DownloadRetries, "download-retries", cos.DefaultDownloadRetries, "number of retries of downloads that failed with a transient error, negative to disable")
+	flags.Var(newSizeValue(&options.MultipartThreshold), "multipart-threshold", "minimum size of objects that are transferred in parts, e.g. 64MiB (default 64MiB)")
+	flags.Var(newSizeValue(&options.PartSize), "part-size", "size of the parts of multipart transfers, at least 5MiB (default 16MiB)")
+	flags.IntVar(&options.TransferConcurrency, "transfer-concurrency", cos.DefaultTransferConcurrency, "maximum number of parts of an object that are transferred in parallel")
+	flags.StringVar(&options.ManifestFile, "manifest-file", "", "local file to record the used entries in, and to prefetch the entries of the previous build from")
+	flags.StringVar(&options.ManifestKey, "manifest-key", "", "key of the manifest in the bucket to record the used entries in, and to prefetch the entries of the previous build from, e.g. the branch")
+	flags.IntVar(&options.PrefetchConcurrency, "prefetch-concurrency", cos.DefaultPrefetchConcurrency, "maximum number of entries that are prefetched in parallel")
+	flags.IntVar(&options.CircuitBreakerThreshold, "circuit-breaker-threshold", cos.DefaultCircuitBreakerThreshold, "number of consecutive failures or slow responses after which only the local cache directory is used, negative to disable")
+	flags.DurationVar(&options.CircuitBreakerLatency, "circuit-breaker-latency", cos.DefaultCircuitBreakerLatency, "time after which a response of the remote storage is considered slow")
+	flags.DurationVar(&options.CircuitBreakerCooldown, "circuit-breaker-cooldown", cos.DefaultCircuitBreakerCooldown, "time after which the remote storage is probed again once only the local cache directory is used")
 }
 
 func mapOsEnvToVarIfSet(key string, target *string) {
@@ -95,13 +143,51 @@ func mapOsEnvToVarIfSet(key string, target *string) {
 	*target = val
 }
 
-func mapOsEnvToConfig(key string, target *cos.Config) {
+// mapOsEnvToSliceIfSet sets the comma separated values of the environment
+// variable, if it is set
+func mapOsEnvToSliceIfSet(key string, target *[]string) {
 	val, found := os.LookupEnv(key)
 	if !found {
 		return
 	}
 
+	*target = nil
+	for value := range strings.SplitSeq(val, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			*target = append(*target, value)
+		}
+	}
+}
+
+// readSecretFile reads a secret, e.g. a key, from the file, if one is
+// configured
+func readSecretFile(path string, target *string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 - file is explicitly configured by the user
+	if err != nil {
+		return err
+	}
+
+	*target = strings.TrimSpace(string(data))
+	return nil
+}
+
+// mapOsEnvToConfig parses the configuration from the environment variable,
+// if it is set, a failure is reported and returned, so that commands can
+// refuse to run with an invalid configuration
+func mapOsEnvToConfig[T any](key string, target *T) error {
+	val, found := os.LookupEnv(key)
+	if !found {
+		return nil
+	}
+
 	if err := json.Unmarshal([]byte(val), target); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to parse configuration from environment variable %q: %v", key, err)
+		return fmt.Errorf("failed to parse configuration from environment variable %q: %w", key, err)
 	}
+
+	return nil
 }
@@ -0,0 +1,94 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"TiB", 1 << 40},
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"TB", 1000 * 1000 * 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"MB", 1000 * 1000},
+	{"KB", 1000},
+	{"B", 1},
+}
+
+// sizeValue is a flag value for sizes in bytes, that also accepts sizes
+// with a unit, e.g. 512MiB or 10GB
+type sizeValue struct {
+	target *int64
+}
+
+func newSizeValue(target *int64) *sizeValue {
+	return &sizeValue{target: target}
+}
+
+func (v *sizeValue) String() string {
+	if v.target == nil || *v.target == 0 {
+		return ""
+	}
+
+	return humanReadableSize(*v.target)
+}
+
+func (v *sizeValue) Set(val string) error {
+	size, err := parseSize(val)
+	if err != nil {
+		return err
+	}
+
+	*v.target = size
+	return nil
+}
+
+func (v *sizeValue) Type() string {
+	return "size"
+}
+
+func parseSize(input string) (int64, error) {
+	val := strings.TrimSpace(input)
+
+	var multiplier int64 = 1
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(val, unit.suffix) {
+			val = strings.TrimSpace(strings.TrimSuffix(val, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	size, err := strconv.ParseFloat(val, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size %q", input)
+	}
+
+	return int64(size * float64(multiplier)), nil
+}
@@ -0,0 +1,100 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/homeport/go-cache-prog/pkg/cache"
+	"github.com/homeport/go-cache-prog/pkg/provider/httpcache"
+	"github.com/spf13/cobra"
+)
+
+type httpCmdOpts struct {
+	config    httpcache.Config
+	configErr error
+}
+
+var httpCmdSettings httpCmdOpts
+
+var httpCmd = &cobra.Command{
+	Use:           "http",
+	Short:         "Use HTTP cache server as cache backend",
+	Long:          `Use HTTP cache server (e.g. bazel-remote, WebDAV, or go-cache-prog serve) as cache backend`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if httpCmdSettings.configErr != nil {
+			return httpCmdSettings.configErr
+		}
+
+		provider, err := httpcache.NewProvider(httpCmdSettings.config)
+		if err != nil {
+			return err
+		}
+
+		handler := cache.NewWithContextProvider(os.Stdin, os.Stdout, provider).WithConcurrentWorkers(rootCmdSettings.workers)
+
+		if rootCmdSettings.logfile != "" {
+			file, err := os.OpenFile(rootCmdSettings.logfile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = file.Close() }()
+
+			handler.WithLogOutput(file)
+			provider.WithLogOutput(file)
+		}
+
+		return handler.Run(cmd.Context())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(httpCmd)
+	httpCmd.Flags().SortFlags = false
+
+	httpCmd.PersistentFlags().StringVar(&httpCmdSettings.config.CacheDir, "cache-dir", filepath.Join(os.TempDir(), "go-cache"), "location of the local cache directory")
+	httpCmd.PersistentFlags().BoolVar(&httpCmdSettings.config.Fsync, "fsync", false, "sync local cache files to stable storage before making them visible")
+	httpCmd.PersistentFlags().BoolVar(&httpCmdSettings.config.VerifyPuts, "verify-puts", false, "verify that stored content matches its output id")
+	httpCmd.PersistentFlags().BoolVar(&httpCmdSettings.config.SkipDownloadVerify, "skip-download-verify", false, "skip verification that downloaded content matches its output id")
+	httpCmd.PersistentFlags().Var(newSizeValue(&httpCmdSettings.config.MaxSize), "max-size", "maximum size of the local cache directory, e.g. 10GiB (default no limit)")
+	httpCmd.PersistentFlags().DurationVar(&httpCmdSettings.config.MaxAge, "max-age", 0, "maximum time an unused entry is kept in the local cache directory (default no limit)")
+	httpCmd.PersistentFlags().BoolVar(&httpCmdSettings.config.ReadOnly, "read-only", false, "store entries in the local cache directory only and never write to the remote cache")
+
+	httpCmd.PersistentFlags().StringVar(&httpCmdSettings.config.HTTP.URL, "url", "", "specify URL of the HTTP cache server")
+	httpCmd.PersistentFlags().StringVar(&httpCmdSettings.config.HTTP.BearerToken, "token", "", "specify bearer token for the HTTP cache server")
+	httpCmd.PersistentFlags().StringVar(&httpCmdSettings.config.HTTP.Username, "username", "", "specify username for basic auth")
+	httpCmd.PersistentFlags().StringVar(&httpCmdSettings.config.HTTP.Password, "password", "", "specify password for basic auth")
+	httpCmd.PersistentFlags().StringToStringVar(&httpCmdSettings.config.HTTP.Headers, "header", nil, "specify additional header to be sent, e.g. X-Api-Key=secret")
+	httpCmd.PersistentFlags().StringVar(&httpCmdSettings.config.HTTP.CACertFile, "ca-cert", "", "specify file with CA certificates to verify the server")
+	httpCmd.PersistentFlags().StringVar(&httpCmdSettings.config.HTTP.ClientCertFile, "client-cert", "", "specify client certificate file for mutual TLS")
+	httpCmd.PersistentFlags().StringVar(&httpCmdSettings.config.HTTP.ClientKeyFile, "client-key", "", "specify client key file for mutual TLS")
+	httpCmd.PersistentFlags().BoolVar(&httpCmdSettings.config.HTTP.InsecureSkipVerify, "insecure-skip-verify", false, "skip verification of the server certificate")
+	httpCmd.PersistentFlags().DurationVar(&httpCmdSettings.config.HTTP.Timeout, "timeout", httpcache.DefaultTimeout, "timeout of requests to the HTTP cache server")
+
+	httpCmdSettings.configErr = mapOsEnvToConfig("GO_CACHE_PROG_HTTP_CONFIG", &httpCmdSettings.config)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_HTTP_URL", &httpCmdSettings.config.HTTP.URL)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_HTTP_TOKEN", &httpCmdSettings.config.HTTP.BearerToken)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_HTTP_READONLY_TOKEN", &httpCmdSettings.config.HTTP.ReadOnlyBearerToken)
+}
@@ -21,7 +21,9 @@
 package cmd
 
 import (
+	"log"
 	"os"
+	"time"
 
 	"github.com/homeport/go-cache-prog/pkg/cache"
 	"github.com/homeport/go-cache-prog/pkg/provider/local"
@@ -30,6 +32,10 @@ import (
 
 type localCmdOpts struct {
 	cacheDir string
+	fsync    bool
+	verify   bool
+	maxSize  int64
+	maxAge   time.Duration
 }
 
 var localCmdSettings localCmdOpts
@@ -48,6 +54,12 @@ var localCmd = &cobra.Command{
 			return err
 		}
 
+		provider.
+			WithSync(localCmdSettings.fsync).
+			WithVerify(localCmdSettings.verify).
+			WithMaxSize(localCmdSettings.maxSize).
+			WithMaxAge(localCmdSettings.maxAge)
+
 		handler := cache.New(os.Stdin, os.Stdout, provider).
 			WithConcurrentWorkers(rootCmdSettings.workers)
 
@@ -59,6 +71,7 @@ var localCmd = &cobra.Command{
 			defer func() { _ = file.Close() }()
 
 			handler.WithLogOutput(file)
+			provider.WithLogger(log.New(file, "", log.LstdFlags))
 		}
 
 		return handler.Run(cmd.Context())
@@ -70,4 +83,8 @@ func init() {
 
 	localCmd.Flags().SortFlags = false
 	localCmd.Flags().StringVar(&localCmdSettings.cacheDir, "cache-dir", "/tmp/go-cache", "location of the local cache directory")
+	localCmd.Flags().BoolVar(&localCmdSettings.fsync, "fsync", false, "sync cache files to stable storage before making them visible")
+	localCmd.Flags().BoolVar(&localCmdSettings.verify, "verify-puts", false, "verify that stored content matches its output id")
+	localCmd.Flags().Var(newSizeValue(&localCmdSettings.maxSize), "max-size", "maximum size of the cache directory, e.g. 10GiB (default no limit)")
+	localCmd.Flags().DurationVar(&localCmdSettings.maxAge, "max-age", 0, "maximum time an unused entry is kept in the cache directory (default no limit)")
 }
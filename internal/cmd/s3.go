@@ -0,0 +1,103 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/homeport/go-cache-prog/pkg/cache"
+	"github.com/homeport/go-cache-prog/pkg/provider/s3"
+	"github.com/spf13/cobra"
+)
+
+type s3CmdOpts struct {
+	config            s3.Config
+	signingKeyFile    string
+	encryptionKeyFile string
+}
+
+var s3CmdSettings s3CmdOpts
+
+var s3Cmd = &cobra.Command{
+	Use:           "s3",
+	Short:         "Use S3 compatible storage (AWS S3, MinIO, Ceph) as cache backend",
+	Long:          `Use S3 compatible storage (AWS S3, MinIO, Ceph) as cache backend`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := readSecretFile(s3CmdSettings.signingKeyFile, &s3CmdSettings.config.SigningKey); err != nil {
+			return err
+		}
+
+		if err := readSecretFile(s3CmdSettings.encryptionKeyFile, &s3CmdSettings.config.EncryptionKey); err != nil {
+			return err
+		}
+
+		provider, err := s3.NewProvider(s3CmdSettings.config)
+		if err != nil {
+			return err
+		}
+
+		handler := cache.NewWithContextProvider(os.Stdin, os.Stdout, provider).WithConcurrentWorkers(rootCmdSettings.workers)
+
+		if rootCmdSettings.logfile != "" {
+			file, err := os.OpenFile(rootCmdSettings.logfile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = file.Close() }()
+
+			handler.WithLogOutput(file)
+			provider.SetLogOutput(file)
+		}
+
+		return handler.Run(cmd.Context())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(s3Cmd)
+	s3Cmd.Flags().SortFlags = false
+
+	addOptionsFlags(s3Cmd.PersistentFlags(), &s3CmdSettings.config.Options)
+	s3Cmd.PersistentFlags().StringVar(&s3CmdSettings.signingKeyFile, "signing-key-file", "", "file with the secret used to sign uploads and verify downloads")
+	s3Cmd.PersistentFlags().StringVar(&s3CmdSettings.encryptionKeyFile, "encryption-key-file", "", "file with the hex or base64 encoded 256 bit key used to encrypt uploads")
+
+	s3Cmd.PersistentFlags().StringVar(&s3CmdSettings.config.S3.Endpoint, "endpoint", "", "specify URL endpoint of the S3 compatible storage (default AWS S3)")
+	s3Cmd.PersistentFlags().StringVar(&s3CmdSettings.config.S3.Region, "region", "", "specify region of the bucket")
+	s3Cmd.PersistentFlags().StringVar(&s3CmdSettings.config.S3.Bucket, "bucket", "", "specify bucket to be used")
+	s3Cmd.PersistentFlags().StringVar(&s3CmdSettings.config.S3.Profile, "profile", "", "specify profile of the shared credentials file")
+	s3Cmd.PersistentFlags().BoolVar(&s3CmdSettings.config.S3.PathStyle, "path-style", false, "use path-style instead of virtual host style addressing")
+	s3Cmd.PersistentFlags().StringVar(&s3CmdSettings.config.S3.BucketCheck, "bucket-check", s3.BucketCheckHead, "validation of the bucket on start-up (head, list, or none)")
+
+	mapOsEnvToConfig("GO_CACHE_PROG_S3_CONFIG", &s3CmdSettings.config)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_SIGNING_KEY", &s3CmdSettings.config.SigningKey)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_ENCRYPTION_KEY", &s3CmdSettings.config.EncryptionKey)
+	mapOsEnvToVarIfSet("AWS_REGION", &s3CmdSettings.config.S3.Region)
+	mapOsEnvToVarIfSet("AWS_PROFILE", &s3CmdSettings.config.S3.Profile)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_S3_ENDPOINT", &s3CmdSettings.config.S3.Endpoint)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_S3_REGION", &s3CmdSettings.config.S3.Region)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_S3_BUCKET", &s3CmdSettings.config.S3.Bucket)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_S3_READONLY_ACCESSKEYID", &s3CmdSettings.config.S3.ReadOnlyAccessKeyID)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_S3_READONLY_SECRETACCESSKEY", &s3CmdSettings.config.S3.ReadOnlySecretAccessKey)
+	mapOsEnvToVarIfSet("GO_CACHE_PROG_S3_READONLY_PROFILE", &s3CmdSettings.config.S3.ReadOnlyProfile)
+}
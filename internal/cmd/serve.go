@@ -0,0 +1,296 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/homeport/go-cache-prog/pkg/cache"
+	"github.com/homeport/go-cache-prog/pkg/provider/cos"
+	"github.com/homeport/go-cache-prog/pkg/provider/local"
+	"github.com/homeport/go-cache-prog/pkg/provider/s3"
+	"github.com/homeport/go-cache-prog/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+type serveCmdOpts struct {
+	listen   string
+	backend  string
+	cacheDir string
+	maxSize  int64
+	maxAge   time.Duration
+	tlsCert  string
+	tlsKey   string
+
+	readWriteTokens    []string
+	readOnlyTokens     []string
+	readWriteTokenFile string
+	readOnlyTokenFile  string
+	quota              int64
+
+	config server.Config
+}
+
+var serveCmdSettings serveCmdOpts
+
+// serveTrimInterval is the interval in which the cache directory of the local
+// backend is trimmed, the local provider skips trim passes that follow the
+// previous one too closely
+const serveTrimInterval = 10 * time.Minute
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run an HTTP cache server to be used with the http command",
+	Long: `Run an HTTP cache server to be used with the http command
+
+The server uses the local directory, or IBM Cloud Object Storage, or S3 as
+storage. Both remote backends are configured using the same environment
+variables as the cos and s3 commands.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var logOutput io.Writer = os.Stderr
+		if rootCmdSettings.logfile != "" {
+			file, err := os.OpenFile(rootCmdSettings.logfile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = file.Close() }()
+
+			logOutput = file
+		}
+
+		readWriteTokens, err := readTokenFile(serveCmdSettings.readWriteTokenFile)
+		if err != nil {
+			return err
+		}
+
+		readOnlyTokens, err := readTokenFile(serveCmdSettings.readOnlyTokenFile)
+		if err != nil {
+			return err
+		}
+
+		config := serveCmdSettings.config
+		for _, token := range slices.Concat(serveCmdSettings.readWriteTokens, readWriteTokens) {
+			config.Tokens = append(config.Tokens, server.Token{Token: token, Access: server.AccessReadWrite, Quota: serveCmdSettings.quota})
+		}
+
+		for _, token := range slices.Concat(serveCmdSettings.readOnlyTokens, readOnlyTokens) {
+			config.Tokens = append(config.Tokens, server.Token{Token: token, Access: server.AccessReadOnly})
+		}
+
+		provider, trim, err := newServeBackend(logOutput)
+		if err != nil {
+			return err
+		}
+
+		srv, err := server.New(provider, config)
+		if err != nil {
+			return err
+		}
+
+		srv.WithLogOutput(logOutput)
+
+		httpServer := &http.Server{
+			Addr:              serveCmdSettings.listen,
+			Handler:           srv,
+			ReadHeaderTimeout: 10 * time.Second,
+			ErrorLog:          log.New(logOutput, "", log.LstdFlags),
+		}
+
+		errs := make(chan error, 1)
+		go func() {
+			if serveCmdSettings.tlsCert != "" || serveCmdSettings.tlsKey != "" {
+				errs <- httpServer.ListenAndServeTLS(serveCmdSettings.tlsCert, serveCmdSettings.tlsKey)
+				return
+			}
+
+			errs <- httpServer.ListenAndServe()
+		}()
+
+		fmt.Fprintf(logOutput, "Serving cache on %s using %s backend\n", serveCmdSettings.listen, serveCmdSettings.backend)
+
+		stopTrimming := trimPeriodically(trim, logOutput)
+
+		select {
+		case err := <-errs:
+			stopTrimming()
+			_ = srv.Close(context.Background())
+			return err
+
+		case <-cmd.Context().Done():
+		}
+
+		// Graceful shutdown, let running requests and pending uploads finish
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := httpServer.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+
+		stopTrimming()
+		return srv.Close(ctx)
+	},
+}
+
+// newServeBackend returns the provider of the backend, and a function that
+// trims its cache directory, if the backend supports trimming while running.
+// The cos and s3 backends trim their cache directory when they are closed.
+func newServeBackend(logOutput io.Writer) (cache.ContextProvider, func() error, error) {
+	switch serveCmdSettings.backend {
+	case "local":
+		provider, err := local.NewProvider(serveCmdSettings.cacheDir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		provider.
+			WithMaxSize(serveCmdSettings.maxSize).
+			WithMaxAge(serveCmdSettings.maxAge).
+			WithLogger(log.New(logOutput, "", log.LstdFlags))
+
+		return cache.WithContext(provider), provider.Trim, nil
+
+	case "cos":
+		config := cosCmdSettings.config
+		config.CacheDir = serveCmdSettings.cacheDir
+
+		provider, err := cos.NewProvider(config)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return provider.WithLogOutput(logOutput), nil, nil
+
+	case "s3":
+		config := s3CmdSettings.config
+		config.CacheDir = serveCmdSettings.cacheDir
+
+		provider, err := s3.NewProvider(config)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		provider.SetLogOutput(logOutput)
+		return provider, nil, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported backend %q, supported are local, cos, and s3", serveCmdSettings.backend)
+	}
+}
+
+// trimPeriodically trims the cache directory in the background, since a long
+// running server would otherwise only trim it when it is closed. The returned
+// function stops trimming and waits for a running trim pass.
+func trimPeriodically(trim func() error, logOutput io.Writer) func() {
+	if trim == nil {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(serveTrimInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := trim(); err != nil {
+					fmt.Fprintf(logOutput, "Failed to trim cache directory: %v\n", err)
+				}
+
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// readTokenFile reads the tokens from the file, one per line, empty lines
+// and lines starting with # are ignored
+func readTokenFile(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 - file is explicitly configured by the user
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []string
+	for line := range strings.Lines(string(data)) {
+		token := strings.TrimSpace(line)
+		if token == "" || strings.HasPrefix(token, "#") {
+			continue
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().SortFlags = false
+
+	serveCmd.Flags().StringVar(&serveCmdSettings.listen, "listen", ":8080", "address to listen on")
+	serveCmd.Flags().StringVar(&serveCmdSettings.backend, "backend", "local", "storage backend of the server (local, cos, or s3)")
+	serveCmd.Flags().StringVar(&serveCmdSettings.cacheDir, "cache-dir", filepath.Join(os.TempDir(), "go-cache-serve"), "location of the local cache directory")
+	serveCmd.Flags().Var(newSizeValue(&serveCmdSettings.maxSize), "max-size", "maximum size of the cache directory of the local backend, e.g. 100GiB (default no limit)")
+	serveCmd.Flags().DurationVar(&serveCmdSettings.maxAge, "max-age", 0, "maximum time an unused entry is kept in the cache directory of the local backend (default no limit)")
+	serveCmd.Flags().StringVar(&serveCmdSettings.tlsCert, "tls-cert", "", "certificate file to serve using TLS")
+	serveCmd.Flags().StringVar(&serveCmdSettings.tlsKey, "tls-key", "", "key file to serve using TLS")
+	serveCmd.Flags().StringSliceVar(&serveCmdSettings.readWriteTokens, "read-write-token", nil, "bearer token with read and write access")
+	serveCmd.Flags().StringSliceVar(&serveCmdSettings.readOnlyTokens, "read-only-token", nil, "bearer token with read access only")
+	serveCmd.Flags().StringVar(&serveCmdSettings.readWriteTokenFile, "read-write-token-file", "", "file with bearer tokens with read and write access, one per line")
+	serveCmd.Flags().StringVar(&serveCmdSettings.readOnlyTokenFile, "read-only-token-file", "", "file with bearer tokens with read access only, one per line")
+	serveCmd.Flags().Var(newSizeValue(&serveCmdSettings.quota), "quota", "maximum upload size per read-write token and quota period, e.g. 10GiB (default no limit)")
+	serveCmd.Flags().DurationVar(&serveCmdSettings.config.QuotaPeriod, "quota-period", server.DefaultQuotaPeriod, "period after which the quota is reset")
+	serveCmd.Flags().DurationVar(&serveCmdSettings.config.StagingTimeout, "staging-timeout", server.DefaultStagingTimeout, "time after which uploaded objects without an action entry are removed")
+	serveCmd.Flags().StringVar(&serveCmdSettings.config.Anonymous, "anonymous", server.AccessNone, "access of requests without token (none, read-only, or read-write)")
+
+	mapOsEnvToConfig("GO_CACHE_PROG_SERVE_CONFIG", &serveCmdSettings.config)
+	mapOsEnvToSliceIfSet("GO_CACHE_PROG_SERVE_READ_WRITE_TOKENS", &serveCmdSettings.readWriteTokens)
+	mapOsEnvToSliceIfSet("GO_CACHE_PROG_SERVE_READ_ONLY_TOKENS", &serveCmdSettings.readOnlyTokens)
+}
@@ -0,0 +1,245 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/homeport/go-cache-prog/pkg/cache"
+	"github.com/homeport/go-cache-prog/pkg/provider/cos"
+	"github.com/homeport/go-cache-prog/pkg/provider/httpcache"
+	"github.com/homeport/go-cache-prog/pkg/provider/local"
+	"github.com/homeport/go-cache-prog/pkg/provider/s3"
+	"github.com/homeport/go-cache-prog/pkg/provider/tiered"
+	"github.com/spf13/cobra"
+)
+
+type tieredCmdOpts struct {
+	configFile string
+	config     tieredConfig
+}
+
+type tieredConfig struct {
+	WriteMode string       `json:"write_mode"`
+	Tiers     []tierConfig `json:"tiers"`
+}
+
+// tierConfig configures one tier, the config is the same as the respective
+// command uses, i.e. local uses the local cache options only
+type tierConfig struct {
+	Type   string          `json:"type"`
+	Write  *bool           `json:"write"`
+	Config json.RawMessage `json:"config"`
+}
+
+var tieredCmdSettings tieredCmdOpts
+
+var tieredCmd = &cobra.Command{
+	Use:   "tiered",
+	Short: "Use a chain of cache backends",
+	Long: `Use a chain of cache backends
+
+The tiers are configured in order from the fastest to the slowest, entries
+found in a slower tier are back-filled into the faster tiers. Puts are
+written into all tiers that are writable, with write mode "through" before
+the put completes, with write mode "back" only the first writable tier is
+written before the put completes.
+
+Example configuration:
+
+  {
+    "write_mode": "back",
+    "tiers": [
+      {"type": "local", "config": {"cache_dir": "/tmp/go-cache"}},
+      {"type": "http", "config": {"cache_dir": "/tmp/go-cache-http", "http": {"url": "https://cache.example.com"}}},
+      {"type": "s3", "write": false, "config": {"cache_dir": "/tmp/go-cache-s3", "s3": {"bucket": "go-cache"}}}
+    ]
+  }`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config := tieredCmdSettings.config
+		if tieredCmdSettings.configFile != "" {
+			data, err := os.ReadFile(tieredCmdSettings.configFile)
+			if err != nil {
+				return err
+			}
+
+			config = tieredConfig{}
+			if err := json.Unmarshal(data, &config); err != nil {
+				return fmt.Errorf("failed to parse configuration file %q: %w", tieredCmdSettings.configFile, err)
+			}
+		}
+
+		var logOutput io.Writer = io.Discard
+		if rootCmdSettings.logfile != "" {
+			file, err := os.OpenFile(rootCmdSettings.logfile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = file.Close() }()
+
+			logOutput = file
+		}
+
+		var (
+			tiers     []tiered.Tier
+			cacheDirs = map[string]int{}
+		)
+
+		// Tiers that were set up already are closed again if a later tier
+		// cannot be set up, e.g. to remove their locks and temporary files
+		closeTiers := func() {
+			for _, tier := range tiers {
+				_ = tier.Provider.Close(cmd.Context())
+			}
+		}
+
+		for i, tierConfig := range config.Tiers {
+			provider, cacheDir, err := newTier(i, tierConfig, logOutput)
+			if err != nil {
+				closeTiers()
+				return fmt.Errorf("failed to set up tier #%d: %w", i, err)
+			}
+
+			tiers = append(tiers, tiered.Tier{
+				Provider: provider,
+				Write:    tierConfig.Write == nil || *tierConfig.Write,
+			})
+
+			// Each tier manages its cache directory on its own, e.g. when
+			// trimming it, so that tiers cannot share one
+			if j, found := cacheDirs[filepath.Clean(cacheDir)]; found {
+				closeTiers()
+				return fmt.Errorf("tier #%d uses the cache directory %s of tier #%d", i, cacheDir, j)
+			}
+
+			cacheDirs[filepath.Clean(cacheDir)] = i
+		}
+
+		provider, err := tiered.NewProvider(tiers, config.WriteMode)
+		if err != nil {
+			closeTiers()
+			return err
+		}
+
+		handler := cache.NewWithContextProvider(os.Stdin, os.Stdout, provider.WithLogOutput(logOutput)).
+			WithConcurrentWorkers(rootCmdSettings.workers).
+			WithLogOutput(logOutput)
+
+		return handler.Run(cmd.Context())
+	},
+}
+
+// newTier sets up the provider of the tier, and returns it with its cache
+// directory, by default each tier uses its own subdirectory
+func newTier(index int, config tierConfig, logOutput io.Writer) (cache.ContextProvider, string, error) {
+	unmarshal := func(target any) error {
+		if len(config.Config) == 0 {
+			return nil
+		}
+
+		return json.Unmarshal(config.Config, target)
+	}
+
+	defaultCacheDir := filepath.Join(os.TempDir(), "go-cache-tiered", strconv.Itoa(index))
+
+	switch config.Type {
+	case "local":
+		options := cos.Options{CacheDir: defaultCacheDir}
+		if err := unmarshal(&options); err != nil {
+			return nil, "", err
+		}
+
+		provider, err := local.NewProvider(options.CacheDir)
+		if err != nil {
+			return nil, "", err
+		}
+
+		provider.
+			WithSync(options.Fsync).
+			WithVerify(options.VerifyPuts).
+			WithMaxSize(options.MaxSize).
+			WithMaxAge(options.MaxAge).
+			WithLogger(log.New(logOutput, "", log.LstdFlags))
+
+		return cache.WithContext(provider), options.CacheDir, nil
+
+	case "cos":
+		cosConfig := cos.Config{Options: cos.Options{CacheDir: defaultCacheDir}}
+		if err := unmarshal(&cosConfig); err != nil {
+			return nil, "", err
+		}
+
+		provider, err := cos.NewProvider(cosConfig)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return provider.WithLogOutput(logOutput), cosConfig.CacheDir, nil
+
+	case "s3":
+		s3Config := s3.Config{Options: cos.Options{CacheDir: defaultCacheDir}}
+		if err := unmarshal(&s3Config); err != nil {
+			return nil, "", err
+		}
+
+		provider, err := s3.NewProvider(s3Config)
+		if err != nil {
+			return nil, "", err
+		}
+
+		provider.SetLogOutput(logOutput)
+		return provider, s3Config.CacheDir, nil
+
+	case "http":
+		httpConfig := httpcache.Config{CacheDir: defaultCacheDir}
+		if err := unmarshal(&httpConfig); err != nil {
+			return nil, "", err
+		}
+
+		provider, err := httpcache.NewProvider(httpConfig)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return provider.WithLogOutput(logOutput), httpConfig.CacheDir, nil
+
+	default:
+		return nil, "", fmt.Errorf("unsupported tier type %q, supported are local, cos, s3, and http", config.Type)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(tieredCmd)
+	tieredCmd.Flags().SortFlags = false
+
+	tieredCmd.Flags().StringVar(&tieredCmdSettings.configFile, "config", "", "file with the JSON configuration of the tiers")
+
+	mapOsEnvToConfig("GO_CACHE_PROG_TIERED_CONFIG", &tieredCmdSettings.config)
+}
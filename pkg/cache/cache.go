@@ -32,21 +32,43 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/homeport/go-cache-prog/pkg/errgroup"
+	"github.com/homeport/go-cache-prog/pkg/singleflight"
 )
 
+var errEndOfInput = errors.New("end of input")
+
+// shutdownTimeout limits how long the provider is given to clean up when
+// Run is cancelled, e.g. by a signal, since the process is about to exit
+const shutdownTimeout = 5 * time.Second
+
 type Handler struct {
 	in       io.Reader
 	out      io.Writer
-	provider Provider
+	provider ContextProvider
 
 	log *log.Logger
 
 	workers int
+
+	// Concurrent requests for the same entry, e.g. from packages that are
+	// built in parallel, share one provider call
+	gets singleflight.Group[entry]
+	puts singleflight.Group[string]
+}
+
+type entry struct {
+	objectId string
+	diskpath string
 }
 
 func New(in io.Reader, out io.Writer, provider Provider) *Handler {
+	return NewWithContextProvider(in, out, WithContext(provider))
+}
+
+func NewWithContextProvider(in io.Reader, out io.Writer, provider ContextProvider) *Handler {
 	return &Handler{
 		in:       in,
 		out:      out,
@@ -66,9 +88,13 @@ func (h *Handler) WithLogOutput(w io.Writer) *Handler {
 	return h
 }
 
-func (h *Handler) Run(_ context.Context) error {
+func (h *Handler) Run(ctx context.Context) error {
+	// All requests share the run context, which is cancelled on end of input
+	// or when Run returns, so that no provider operation is left dangling
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
 	reader := bufio.NewReader(h.in)
-	decoder := json.NewDecoder(reader)
 
 	writer := bufio.NewWriter(h.out)
 	encoder := json.NewEncoder(writer)
@@ -94,94 +120,199 @@ func (h *Handler) Run(_ context.Context) error {
 
 	// ---
 
-	// Limit number of workers to configured number
-	// plus one for the request producer itself
-	g := errgroup.New(h.workers + 1)
-	g.Go(func() error {
-		for {
-			var req progRequest
-			err := decoder.Decode(&req)
-			switch {
-			case errors.Is(err, io.EOF):
-				return nil
+	g, ctx := errgroup.New(ctx, h.workers)
 
-			case err != nil:
-				return fmt.Errorf("failed to decode: %w", err)
-			}
+	// Requests are started by the reader until Run stops accepting them, so
+	// that no request is started after Run waited for the running ones
+	var (
+		spawnMutex sync.Mutex
+		stopped    bool
+	)
 
-			// --- --- ---
+	spawn := func(f func() error) bool {
+		spawnMutex.Lock()
+		defer spawnMutex.Unlock()
+		if stopped {
+			return false
+		}
 
-			switch req.Command {
-			case "get":
-				if len(req.ActionID) == 0 {
-					return fmt.Errorf("invalid ActionID")
-				}
+		g.Go(f)
+		return true
+	}
 
-				g.Go(func() error {
-					resp, err := h.handleGet(&req)
-					if err != nil {
-						return err
-					}
+	type readResult struct {
+		close *progRequest
+		err   error
+	}
 
-					if err := write(resp); err != nil {
-						return err
-					}
+	read := make(chan readResult, 1)
+	go func() {
+		closeReq, err := h.read(ctx, reader, spawn, write)
+		read <- readResult{close: closeReq, err: err}
+	}()
+
+	// Reading the input cannot be interrupted, therefore the reader is not
+	// waited for in case the context is cancelled from the outside (signal)
+	// or a request failed, but the running requests always are
+	var (
+		closeReq *progRequest
+		err      error
+	)
+
+	select {
+	case result := <-read:
+		closeReq, err = result.close, result.err
+
+	case <-ctx.Done():
+		err = context.Cause(ctx)
+	}
 
-					return nil
-				})
+	if errors.Is(err, errEndOfInput) {
+		// The Go toolchain is gone without sending a close command and is
+		// no longer interested in any pending request
+		cancel(errEndOfInput)
+		err = nil
+	}
 
-			case "put":
-				if len(req.OutputID) == 0 {
-					return fmt.Errorf("invalid OutputID")
-				}
+	spawnMutex.Lock()
+	stopped = true
+	spawnMutex.Unlock()
 
-				if req.BodySize > 0 {
-					var body []byte
-					if err := decoder.Decode(&body); err != nil {
-						return err
-					}
+	if groupErr := g.Wait(); err == nil {
+		err = groupErr
+	}
 
-					if req.BodySize != int64(len(body)) {
-						return fmt.Errorf("error processing request #%d, size mismatch: request=%d and body=%d", req.ID, req.BodySize, len(body))
-					}
+	if err != nil {
+		// On shutdown, the provider still gets the chance to clean up, e.g.
+		// to wait for pending uploads, but only with a short deadline
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+			defer cancel()
+
+			if closeErr := h.provider.Close(shutdownCtx); closeErr != nil {
+				h.log.Printf("failed to close cache provider: %v", closeErr)
+			}
+		}
 
-					req.Body = bytes.NewReader(body)
-				}
+		return err
+	}
 
-				if req.Body == nil {
-					req.Body = bytes.NewReader(nil)
-				}
+	// The close command is handled after all other requests are done, so
+	// that the provider is not closed while they are still running
+	if closeReq != nil {
+		return write(h.handleClose(ctx, closeReq))
+	}
 
-				g.Go(func() error {
-					resp, err := h.handlePut(&req)
-					if err != nil {
-						return err
-					}
+	// Give the provider the chance to clean up, even if the Go toolchain
+	// did not send a close command
+	if err := h.provider.Close(context.WithoutCancel(ctx)); err != nil {
+		h.log.Printf("failed to close cache provider: %v", err)
+	}
 
-					if err := write(resp); err != nil {
-						return err
-					}
+	return nil
+}
 
-					return nil
-				})
+// read reads the requests from the input and starts them, until the input
+// ends, a close command is received, or a request is malformed. The close
+// request is returned to be handled once all other requests are done.
+func (h *Handler) read(ctx context.Context, reader *bufio.Reader, spawn func(func() error) bool, write func(any) error) (*progRequest, error) {
+	for {
+		data, err := readObject(reader)
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil, errEndOfInput
+
+		case err != nil:
+			return nil, fmt.Errorf("failed to read: %w", err)
+		}
+
+		var req progRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		// --- --- ---
+
+		var started bool
+		switch req.Command {
+		case "get":
+			if len(req.ActionID) == 0 {
+				return nil, fmt.Errorf("invalid ActionID")
+			}
 
-			case "close":
-				defer g.Done()
-				return h.handleClose(&req)
+			started = spawn(func() error {
+				return write(h.handleGet(ctx, &req))
+			})
+
+		case "put":
+			if len(req.OutputID) == 0 {
+				return nil, fmt.Errorf("invalid OutputID")
+			}
+
+			switch {
+			case req.BodySize < 0:
+				return nil, fmt.Errorf("error processing request #%d, invalid body size %d", req.ID, req.BodySize)
+
+			case req.BodySize == 0:
+				req.Body = bytes.NewReader(nil)
+				started = spawn(func() error {
+					return write(h.handlePut(ctx, &req))
+				})
 
 			default:
-				return fmt.Errorf("unsupported command %q", req.Command)
+				body, err := newBody(reader, req.ID, req.BodySize)
+				if err != nil {
+					return nil, err
+				}
+
+				req.Body = body
+				started = spawn(func() error {
+					resp := h.handlePut(ctx, &req)
+					body.drain()
+					return write(resp)
+				})
+
+				// The body is streamed from the input, therefore it has to be
+				// consumed before the next request can be read
+				if started {
+					if err := body.wait(); err != nil {
+						return nil, err
+					}
+				}
 			}
+
+		case "close":
+			return &req, nil
+
+		default:
+			return nil, fmt.Errorf("unsupported command %q", req.Command)
 		}
-	})
 
-	return g.Wait()
+		if !started {
+			return nil, context.Cause(ctx)
+		}
+	}
 }
 
-func (h *Handler) handleGet(req *progRequest) (*progResponse, error) {
-	pid, diskpath, err := h.provider.Get(enc(req.ActionID))
+// Error handling policy: Only errors that indicate that the communication
+// with the Go toolchain itself is broken are fatal and terminate Run, i.e.
+// failures to decode requests, malformed or unsupported requests, and
+// failures to write responses. Errors reported by the provider only affect
+// the respective request: A failing get is reported as a cache miss, since
+// the toolchain can always recover from a miss by building the action, and
+// a failing put or close is reported back using the response Err field.
+
+func (h *Handler) handleGet(ctx context.Context, req *progRequest) *progResponse {
+	actionId := enc(req.ActionID)
+	result, err, _ := h.gets.Do(actionId, func() (entry, error) {
+		objectId, diskpath, err := h.provider.Get(ctx, actionId)
+		return entry{objectId: objectId, diskpath: diskpath}, err
+	})
+
+	pid, diskpath := result.objectId, result.diskpath
 	if err != nil {
-		return nil, fmt.Errorf("failed to obtain entry from cache: %w", err)
+		h.log.Printf("failed to obtain entry for request #%d from cache, reporting miss: %v", req.ID, err)
+		return cacheMiss(req)
 	}
 
 	if pid == "" && diskpath == "" {
@@ -190,38 +321,60 @@ func (h *Handler) handleGet(req *progRequest) (*progResponse, error) {
 
 	outputID, err := dec(pid)
 	if err != nil {
-		return nil, err
+		h.log.Printf("invalid object id %q for request #%d, reporting miss: %v", pid, req.ID, err)
+		return cacheMiss(req)
 	}
 
-	return cacheHit(req, outputID, diskpath)
+	fi, err := os.Stat(diskpath)
+	if err != nil {
+		h.log.Printf("failed to access %s for request #%d, reporting miss: %v", diskpath, req.ID, err)
+		return cacheMiss(req)
+	}
+
+	return cacheHit(req, outputID, diskpath, fi)
 }
 
-func (h *Handler) handlePut(req *progRequest) (*progResponse, error) {
-	path, err := h.provider.Put(enc(req.ActionID), enc(req.OutputID), req.Body)
+func (h *Handler) handlePut(ctx context.Context, req *progRequest) *progResponse {
+	actionId, objectId := enc(req.ActionID), enc(req.OutputID)
+
+	// The body of a request that shares the result of another request is
+	// not consumed here, but drained afterwards
+	path, err, _ := h.puts.Do(actionId+":"+objectId, func() (string, error) {
+		return h.provider.Put(ctx, actionId, objectId, req.Body)
+	})
+
 	if err != nil {
-		return nil, err
+		h.log.Printf("failed to store entry for request #%d in cache: %v", req.ID, err)
+		return failed(req, err)
+	}
+
+	return &progResponse{ID: req.ID, DiskPath: path}
+}
+
+func (h *Handler) handleClose(ctx context.Context, req *progRequest) *progResponse {
+	if err := h.provider.Close(ctx); err != nil {
+		h.log.Printf("failed to close cache provider: %v", err)
+		return failed(req, err)
 	}
 
-	return &progResponse{ID: req.ID, DiskPath: path}, nil
+	return &progResponse{ID: req.ID}
 }
 
-func (h *Handler) handleClose(_ *progRequest) error {
-	return h.provider.Close()
+func failed(req *progRequest, err error) *progResponse {
+	return &progResponse{
+		ID:  req.ID,
+		Err: err.Error(),
+	}
 }
 
-func cacheMiss(req *progRequest) (*progResponse, error) {
+func cacheMiss(req *progRequest) *progResponse {
 	return &progResponse{
 		ID:   req.ID,
 		Miss: true,
-	}, nil
-}
-
-func cacheHit(req *progRequest, objectId []byte, diskpath string) (*progResponse, error) {
-	fi, err := os.Stat(diskpath)
-	if err != nil {
-		return nil, err
 	}
+}
 
+func cacheHit(req *progRequest, objectId []byte, diskpath string, fi os.FileInfo) *progResponse {
 	modTime := fi.ModTime()
 	return &progResponse{
 		ID:       req.ID,
@@ -229,7 +382,7 @@ func cacheHit(req *progRequest, objectId []byte, diskpath string) (*progResponse
 		Size:     fi.Size(),
 		Time:     &modTime,
 		DiskPath: diskpath,
-	}, nil
+	}
 }
 
 func enc(in []byte) string {
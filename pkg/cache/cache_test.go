@@ -0,0 +1,244 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowProvider reports misses after a delay and records whether it was
+// closed while gets or puts were still running
+type slowProvider struct {
+	delay    time.Duration
+	inflight atomic.Int64
+	done     atomic.Int64
+
+	closed          atomic.Bool
+	closedInflight  atomic.Int64
+	closedCompleted atomic.Int64
+	closedDeadline  atomic.Bool
+}
+
+func (p *slowProvider) KnownCommands() []string {
+	return []string{"get", "put", "close"}
+}
+
+func (p *slowProvider) Get(ctx context.Context, actionId string) (string, string, error) {
+	p.inflight.Add(1)
+	defer p.inflight.Add(-1)
+	defer p.done.Add(1)
+
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+	}
+
+	return "", "", nil
+}
+
+func (p *slowProvider) Put(ctx context.Context, actionId string, objectId string, body io.Reader) (string, error) {
+	p.inflight.Add(1)
+	defer p.inflight.Add(-1)
+	defer p.done.Add(1)
+
+	_, _ = io.Copy(io.Discard, body)
+	time.Sleep(p.delay)
+	return "", fmt.Errorf("not supported")
+}
+
+func (p *slowProvider) Close(ctx context.Context) error {
+	p.closed.Store(true)
+	p.closedInflight.Store(p.inflight.Load())
+	p.closedCompleted.Store(p.done.Load())
+
+	_, hasDeadline := ctx.Deadline()
+	p.closedDeadline.Store(hasDeadline && ctx.Err() == nil)
+	return nil
+}
+
+func requests(reqs ...string) string {
+	return strings.Join(reqs, "\n") + "\n"
+}
+
+func responses(t *testing.T, out []byte) []progResponse {
+	t.Helper()
+
+	var result []progResponse
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var resp progResponse
+		err := decoder.Decode(&resp)
+		if err == io.EOF {
+			return result
+		}
+
+		if err != nil {
+			t.Fatalf("failed to decode responses: %v", err)
+		}
+
+		result = append(result, resp)
+	}
+}
+
+func TestRunClosesAfterPendingRequests(t *testing.T) {
+	provider := &slowProvider{delay: 50 * time.Millisecond}
+
+	in := requests(
+		`{"ID":1,"Command":"get","ActionID":"AQ=="}`,
+		`{"ID":2,"Command":"get","ActionID":"Ag=="}`,
+		`{"ID":3,"Command":"put","ActionID":"Aw==","OutputID":"BA==","BodySize":3}`,
+		`"Zm9v"`,
+		`{"ID":4,"Command":"close"}`,
+	)
+
+	var out bytes.Buffer
+	if err := NewWithContextProvider(strings.NewReader(in), &out, provider).WithConcurrentWorkers(4).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !provider.closed.Load() {
+		t.Fatal("provider was not closed")
+	}
+
+	if inflight, completed := provider.closedInflight.Load(), provider.closedCompleted.Load(); inflight != 0 || completed != 3 {
+		t.Fatalf("provider was closed with %d running and %d completed requests", inflight, completed)
+	}
+
+	resps := responses(t, out.Bytes())
+	if len(resps) != 5 {
+		t.Fatalf("expected 5 responses, got %d", len(resps))
+	}
+
+	if last := resps[len(resps)-1]; last.ID != 4 {
+		t.Fatalf("expected close response last, got response #%d", last.ID)
+	}
+}
+
+func TestRunWaitsForRequestsOnFatalError(t *testing.T) {
+	provider := &slowProvider{delay: 50 * time.Millisecond}
+
+	in := requests(
+		`{"ID":1,"Command":"get","ActionID":"AQ=="}`,
+		`{"ID":2,"Command":"get","ActionID":"Ag=="}`,
+		`{"ID":3,"Command":"unknown"}`,
+	)
+
+	// The output is read without synchronization after Run returned, which
+	// the race detector reports if a request still writes its response
+	var out bytes.Buffer
+	err := NewWithContextProvider(strings.NewReader(in), &out, provider).WithConcurrentWorkers(4).Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "unsupported command") {
+		t.Fatalf("expected unsupported command error, got %v", err)
+	}
+
+	if inflight := provider.inflight.Load(); inflight != 0 {
+		t.Fatalf("Run returned with %d running requests", inflight)
+	}
+
+	if resps := responses(t, out.Bytes()); len(resps) != 3 {
+		t.Fatalf("expected 3 responses, got %d", len(resps))
+	}
+}
+
+func TestRunDoesNotWaitForBlockedInput(t *testing.T) {
+	provider := &slowProvider{delay: time.Hour}
+
+	// The input is never closed, so that the reader stays blocked
+	in, w := io.Pipe()
+	defer func() { _ = w.Close() }()
+
+	go func() {
+		_, _ = io.WriteString(w, requests(`{"ID":1,"Command":"get","ActionID":"AQ=="}`))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- NewWithContextProvider(in, &out, provider).Run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected error of cancelled context")
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+
+	if inflight := provider.inflight.Load(); inflight != 0 {
+		t.Fatalf("Run returned with %d running requests", inflight)
+	}
+
+	if !provider.closed.Load() || !provider.closedDeadline.Load() {
+		t.Error("expected provider to be closed with a deadline on shutdown")
+	}
+
+	if resps := responses(t, out.Bytes()); len(resps) != 2 {
+		t.Fatalf("expected 2 responses, got %d", len(resps))
+	}
+}
+
+func TestRunSharesConcurrentGets(t *testing.T) {
+	provider := &slowProvider{delay: 50 * time.Millisecond}
+
+	in := requests(
+		`{"ID":1,"Command":"get","ActionID":"AQ=="}`,
+		`{"ID":2,"Command":"get","ActionID":"AQ=="}`,
+		`{"ID":3,"Command":"get","ActionID":"AQ=="}`,
+		`{"ID":4,"Command":"get","ActionID":"Ag=="}`,
+		`{"ID":5,"Command":"close"}`,
+	)
+
+	var out bytes.Buffer
+	if err := NewWithContextProvider(strings.NewReader(in), &out, provider).WithConcurrentWorkers(4).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The gets of the same action share one call of the provider
+	if calls := provider.done.Load(); calls != 2 {
+		t.Errorf("got %d calls of the provider, want 2", calls)
+	}
+
+	// The first response announces the known commands
+	resps := responses(t, out.Bytes())
+	if len(resps) != 6 {
+		t.Fatalf("expected 6 responses, got %d", len(resps))
+	}
+
+	for _, resp := range resps[1:5] {
+		if !resp.Miss {
+			t.Errorf("expected response #%d to be a miss", resp.ID)
+		}
+	}
+}
@@ -21,6 +21,8 @@
 package cache
 
 import (
+	"context"
+	"errors"
 	"io"
 	"time"
 )
@@ -33,6 +35,76 @@ type Provider interface {
 	Close() error
 }
 
+// ContextProvider is a Provider that supports cancellation. The context
+// passed to Get and Put is cancelled when the handler shuts down, i.e. on
+// end of input or when the handler context is cancelled. It can therefore
+// also be used for background work that is started by a request and is
+// waited for in Close.
+type ContextProvider interface {
+	KnownCommands() []string
+
+	Get(ctx context.Context, actionId string) (objectId string, diskpath string, err error)
+	Put(ctx context.Context, actionId string, objectId string, body io.Reader) (diskpath string, err error)
+	Close(ctx context.Context) error
+}
+
+// ObjectLookup is implemented by providers that can look up an object by
+// its id (OutputID) independent of an action, e.g. to serve it to others.
+// An empty diskpath reports that the object does not exist.
+type ObjectLookup interface {
+	Object(objectId string) (diskpath string, err error)
+}
+
+type contextAdapter struct {
+	provider Provider
+}
+
+var _ ContextProvider = &contextAdapter{}
+var _ ObjectLookup = &contextAdapter{}
+
+// WithContext adapts a Provider without context support to be used as a
+// ContextProvider, a cancelled context only prevents new calls
+func WithContext(provider Provider) ContextProvider {
+	return &contextAdapter{provider: provider}
+}
+
+func (a *contextAdapter) KnownCommands() []string {
+	return a.provider.KnownCommands()
+}
+
+func (a *contextAdapter) Get(ctx context.Context, actionId string) (string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+
+	return a.provider.Get(actionId)
+}
+
+func (a *contextAdapter) Put(ctx context.Context, actionId string, objectId string, body io.Reader) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return a.provider.Put(actionId, objectId, body)
+}
+
+func (a *contextAdapter) Object(objectId string) (string, error) {
+	lookup, ok := a.provider.(ObjectLookup)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+
+	return lookup.Object(objectId)
+}
+
+func (a *contextAdapter) Close(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.provider.Close()
+}
+
 // TBD https://pkg.go.dev/cmd/go/internal/cache#ProgRequest
 type progRequest struct {
 	ID      int64
@@ -0,0 +1,255 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// readObject reads the next JSON object from the input stream. In contrast
+// to a JSON decoder, it does not read ahead, so that a subsequent request
+// body can be streamed directly from the same reader.
+func readObject(r *bufio.Reader) ([]byte, error) {
+	if err := skipWhitespace(r); err != nil {
+		return nil, err
+	}
+
+	if c, err := r.Peek(1); err != nil {
+		return nil, err
+	} else if c[0] != '{' {
+		return nil, fmt.Errorf("unexpected character %q, expected start of object", c[0])
+	}
+
+	var (
+		buf      []byte
+		depth    int
+		inString bool
+		escaped  bool
+	)
+
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		buf = append(buf, c)
+
+		switch {
+		case escaped:
+			escaped = false
+
+		case inString && c == '\\':
+			escaped = true
+
+		case inString && c == '"':
+			inString = false
+
+		case inString:
+
+		case c == '"':
+			inString = true
+
+		case c == '{' || c == '[':
+			depth++
+
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return buf, nil
+			}
+		}
+	}
+}
+
+func skipWhitespace(r *bufio.Reader) error {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+
+		return r.UnreadByte()
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// body streams the base64 encoded JSON string of a put request from the
+// input stream, while checking the decoded size against the expected size.
+type body struct {
+	id       int64
+	size     int64
+	read     int64
+	src      io.Reader
+	finished bool
+	err      error
+
+	done chan struct{}
+	once sync.Once
+}
+
+func newBody(r *bufio.Reader, id int64, size int64) (*body, error) {
+	if err := skipWhitespace(r); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	if c != '"' {
+		return nil, fmt.Errorf("error processing request #%d, unexpected character %q, expected start of body", id, c)
+	}
+
+	return &body{
+		id:   id,
+		size: size,
+		src:  base64.NewDecoder(base64.StdEncoding, &quotedReader{r: r}),
+		done: make(chan struct{}),
+	}, nil
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.finished {
+		if b.err != nil {
+			return 0, b.err
+		}
+
+		return 0, io.EOF
+	}
+
+	n, err := b.src.Read(p)
+	b.read += int64(n)
+
+	switch {
+	case b.read > b.size:
+		return n, b.finish(b.mismatch())
+
+	case errors.Is(err, io.EOF):
+		if b.read != b.size {
+			return n, b.finish(b.mismatch())
+		}
+
+		_ = b.finish(nil)
+		return n, io.EOF
+
+	case err != nil:
+		return n, b.finish(fmt.Errorf("error processing request #%d: %w", b.id, unexpectedEOF(err)))
+	}
+
+	return n, nil
+}
+
+func (b *body) mismatch() error {
+	return fmt.Errorf("error processing request #%d, size mismatch: request=%d and body=%d", b.id, b.size, b.read)
+}
+
+func (b *body) finish(err error) error {
+	b.finished = true
+	b.err = err
+	b.once.Do(func() { close(b.done) })
+	return err
+}
+
+// drain consumes whatever the provider did not read from the body, so that
+// the input stream is positioned at the next request
+func (b *body) drain() {
+	_, _ = io.Copy(io.Discard, b)
+}
+
+// wait blocks until the body is fully consumed and reports whether it was
+// well-formed
+func (b *body) wait() error {
+	<-b.done
+	return b.err
+}
+
+// quotedReader reads the content of a JSON string up to its closing quote
+type quotedReader struct {
+	r   *bufio.Reader
+	eof bool
+}
+
+func (q *quotedReader) Read(p []byte) (int, error) {
+	if q.eof {
+		return 0, io.EOF
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if _, err := q.r.Peek(1); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	chunk, _ := q.r.Peek(q.r.Buffered())
+	switch i := bytes.IndexAny(chunk, `"\`); i {
+	case 0:
+		c, _ := q.r.ReadByte()
+		if c == '"' {
+			q.eof = true
+			return 0, io.EOF
+		}
+
+		// Base64 only requires the (optional) escaping of the slash
+		escaped, err := q.r.ReadByte()
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+
+		if escaped != '/' {
+			return 0, fmt.Errorf("unexpected escape sequence \\%c in body", escaped)
+		}
+
+		p[0] = '/'
+		return 1, nil
+
+	case -1:
+		n := copy(p, chunk)
+		_, _ = q.r.Discard(n)
+		return n, nil
+
+	default:
+		n := copy(p, chunk[:i])
+		_, _ = q.r.Discard(n)
+		return n, nil
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadObject(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		rest  string
+		err   error
+	}{
+		{
+			name:  "simple object",
+			input: `{"ID":1,"Command":"get"}`,
+			want:  `{"ID":1,"Command":"get"}`,
+		},
+		{
+			name:  "leading whitespace and following data",
+			input: " \r\n\t{\"ID\":1}\n\"Zm9v\"",
+			want:  `{"ID":1}`,
+			rest:  "\n\"Zm9v\"",
+		},
+		{
+			name:  "nested objects and arrays",
+			input: `{"a":{"b":{"c":[1,{"d":[]}]}},"e":2}{"next":true}`,
+			want:  `{"a":{"b":{"c":[1,{"d":[]}]}},"e":2}`,
+			rest:  `{"next":true}`,
+		},
+		{
+			name:  "escaped quotes in strings",
+			input: `{"a":"say \"hi\"","b":"\\"}x`,
+			want:  `{"a":"say \"hi\"","b":"\\"}`,
+			rest:  `x`,
+		},
+		{
+			name:  "braces and brackets in strings",
+			input: `{"a":"}}]]","b":"{[\"}"}x`,
+			want:  `{"a":"}}]]","b":"{[\"}"}`,
+			rest:  `x`,
+		},
+		{
+			name:  "truncated object",
+			input: `{"ID":1,"Command":"ge`,
+			err:   io.ErrUnexpectedEOF,
+		},
+		{
+			name:  "truncated within nested object",
+			input: `{"a":{"b":1}`,
+			err:   io.ErrUnexpectedEOF,
+		},
+		{
+			name:  "end of input",
+			input: " \n",
+			err:   io.EOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A small buffer fed one byte at a time, so that objects are
+			// split across buffer boundaries
+			r := bufio.NewReaderSize(iotest.OneByteReader(strings.NewReader(tt.input)), 16)
+
+			got, err := readObject(r)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if tt.err != nil {
+				return
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("expected object %s, got %s", tt.want, got)
+			}
+
+			rest, _ := io.ReadAll(r)
+			if string(rest) != tt.rest {
+				t.Errorf("expected remaining input %q, got %q", tt.rest, rest)
+			}
+		})
+	}
+}
+
+func TestReadObjectUnexpectedCharacter(t *testing.T) {
+	if _, err := readObject(bufio.NewReader(strings.NewReader(`["a"]`))); err == nil {
+		t.Fatal("expected error for input that is not an object")
+	}
+}
+
+func TestBody(t *testing.T) {
+	content := strings.Repeat("0123456789abcdef?>", 100)
+	encoded := base64.StdEncoding.EncodeToString([]byte(content))
+
+	tests := []struct {
+		name    string
+		input   string
+		size    int64
+		want    string
+		rest    string
+		wantErr string
+	}{
+		{
+			name:  "body split across buffer boundaries",
+			input: ` "` + encoded + `"` + "\n{}",
+			size:  int64(len(content)),
+			want:  content,
+			rest:  "\n{}",
+		},
+		{
+			name:  "escaped slashes",
+			input: `"` + strings.ReplaceAll(base64.StdEncoding.EncodeToString([]byte("???>>>")), "/", `\/`) + `"`,
+			size:  6,
+			want:  "???>>>",
+		},
+		{
+			name:  "empty body",
+			input: `""`,
+			size:  0,
+			want:  "",
+		},
+		{
+			name:    "body shorter than size",
+			input:   `"` + encoded + `"`,
+			size:    int64(len(content)) + 1,
+			wantErr: "size mismatch",
+		},
+		{
+			name:    "body longer than size",
+			input:   `"` + encoded + `"`,
+			size:    int64(len(content)) - 1,
+			wantErr: "size mismatch",
+		},
+		{
+			name:    "truncated body",
+			input:   `"` + encoded[:len(encoded)/2],
+			size:    int64(len(content)),
+			wantErr: io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:    "invalid escape sequence",
+			input:   `"Zm9v\n"`,
+			size:    3,
+			wantErr: "unexpected escape sequence",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReaderSize(iotest.OneByteReader(strings.NewReader(tt.input)), 16)
+
+			b, err := newBody(r, 1, tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := io.ReadAll(b)
+			switch {
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+
+				if waitErr := b.wait(); waitErr == nil {
+					t.Error("expected wait to report the error")
+				}
+
+				return
+
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("expected body of %d bytes, got %d bytes", len(tt.want), len(got))
+			}
+
+			if err := b.wait(); err != nil {
+				t.Errorf("unexpected error from wait: %v", err)
+			}
+
+			rest, _ := io.ReadAll(r)
+			if string(rest) != tt.rest {
+				t.Errorf("expected remaining input %q, got %q", tt.rest, rest)
+			}
+		})
+	}
+}
+
+func TestBodyDrain(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader(`"Zm9vYmFy"{"ID":2}`))
+
+	b, err := newBody(r, 1, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Read(make([]byte, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	b.drain()
+	if err := b.wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := readObject(r)
+	if err != nil || string(next) != `{"ID":2}` {
+		t.Fatalf("expected next request, got %s (%v)", next, err)
+	}
+}
+
+func TestNewBodyUnexpectedCharacter(t *testing.T) {
+	if _, err := newBody(bufio.NewReader(strings.NewReader(`123`)), 1, 3); err == nil {
+		t.Fatal("expected error for body that is not a string")
+	}
+}
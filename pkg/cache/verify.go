@@ -0,0 +1,64 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// ErrOutputMismatch is reported when content does not match its OutputID,
+// which the Go toolchain computes as the SHA-256 sum of the content
+var ErrOutputMismatch = errors.New("content does not match output id")
+
+type verifyingReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	objectId string
+}
+
+// NewVerifyingReader returns a reader that hashes the content while it is
+// read and fails with ErrOutputMismatch at the end of the content, if the
+// hash does not match the provided hex encoded object id (OutputID).
+func NewVerifyingReader(r io.Reader, objectId string) io.Reader {
+	return &verifyingReader{
+		r:        r,
+		hash:     sha256.New(),
+		objectId: objectId,
+	}
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	_, _ = v.hash.Write(p[:n])
+
+	if errors.Is(err, io.EOF) {
+		if sum := hex.EncodeToString(v.hash.Sum(nil)); sum != v.objectId {
+			return n, fmt.Errorf("%w: expected %s, but got %s", ErrOutputMismatch, v.objectId, sum)
+		}
+	}
+
+	return n, err
+}
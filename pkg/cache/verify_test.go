@@ -0,0 +1,83 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func outputId(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestVerifyingReader(t *testing.T) {
+	content := []byte("build output")
+
+	tests := []struct {
+		name     string
+		content  []byte
+		objectId string
+		wantErr  bool
+	}{
+		{name: "matching content", content: content, objectId: outputId(content)},
+		{name: "empty content", content: nil, objectId: outputId(nil)},
+		{name: "modified content", content: []byte("build outpuT"), objectId: outputId(content), wantErr: true},
+		{name: "truncated content", content: content[:5], objectId: outputId(content), wantErr: true},
+		{name: "invalid object id", content: content, objectId: "not a sum", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Read in small chunks, so that the sum covers multiple reads
+			r := NewVerifyingReader(iotest.OneByteReader(bytes.NewReader(tt.content)), tt.objectId)
+
+			data, err := io.ReadAll(r)
+			switch {
+			case tt.wantErr && !errors.Is(err, ErrOutputMismatch):
+				t.Fatalf("got %v, want %v", err, ErrOutputMismatch)
+
+			case !tt.wantErr && err != nil:
+				t.Fatal(err)
+			}
+
+			// The content is passed through even if it does not match
+			if !bytes.Equal(data, tt.content) {
+				t.Errorf("got %q, want %q", data, tt.content)
+			}
+		})
+	}
+}
+
+func TestVerifyingReaderPassesErrors(t *testing.T) {
+	failure := errors.New("connection reset")
+	r := NewVerifyingReader(iotest.ErrReader(failure), outputId(nil))
+
+	if _, err := io.ReadAll(r); !errors.Is(err, failure) || errors.Is(err, ErrOutputMismatch) {
+		t.Errorf("got %v, want %v", err, failure)
+	}
+}
@@ -18,9 +18,12 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
+// Package errgroup provides a limited group of goroutines working on
+// subtasks of a common task, which is cancelled on the first error.
 package errgroup
 
 import (
+	"context"
 	"runtime"
 	"sync"
 )
@@ -28,30 +31,33 @@ import (
 type token struct{}
 
 type group struct {
-	sem     chan token
-	wg      sync.WaitGroup
-	errChan chan error
+	sem chan token
+	wg  sync.WaitGroup
+
+	cancel  context.CancelCauseFunc
+	errOnce sync.Once
+	err     error
 }
 
-func New(limit int) *group {
+// New returns a group that runs at most limit goroutines concurrently (the
+// number of CPUs if limit is not positive), and a derived context, which is
+// cancelled by the first goroutine that returns an error. The derived
+// context is also cancelled when the provided context is cancelled, but not
+// when Wait returns.
+func New(ctx context.Context, limit int) (*group, context.Context) {
 	if limit <= 0 {
 		limit = runtime.NumCPU()
 	}
 
+	ctx, cancel := context.WithCancelCause(ctx)
 	return &group{
-		errChan: make(chan error),
-		sem:     make(chan token, limit),
-	}
-}
-
-func (g *group) Done() {
-	g.errChan <- nil
-}
-
-func (g *group) Wait() error {
-	return <-g.errChan
+		sem:    make(chan token, limit),
+		cancel: cancel,
+	}, ctx
 }
 
+// Go runs the function in a new goroutine, it blocks until the number of
+// running goroutines is below the limit of the group
 func (g *group) Go(f func() error) {
 	g.sem <- token{}
 	g.wg.Add(1)
@@ -62,7 +68,17 @@ func (g *group) Go(f func() error) {
 		}()
 
 		if err := f(); err != nil {
-			g.errChan <- err
+			g.errOnce.Do(func() {
+				g.err = err
+				g.cancel(err)
+			})
 		}
 	}()
 }
+
+// Wait blocks until all goroutines of the group are finished and returns
+// the first error (if any) returned by them
+func (g *group) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
@@ -0,0 +1,205 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const DefaultCircuitBreakerThreshold = 3
+const DefaultCircuitBreakerLatency = 2 * time.Second
+const DefaultCircuitBreakerCooldown = time.Minute
+
+// breaker stops using the remote storage after a number of consecutive
+// failures or slow responses, so that remote trouble does not slow down
+// the build. While the breaker is open, only the local cache directory is
+// used, and the remote storage is probed in the background after each
+// cool-down period until it is available again.
+type breaker struct {
+	threshold int
+	latency   time.Duration
+	cooldown  time.Duration
+	probe     func(ctx context.Context) error
+	log       *log.Logger
+
+	mu       sync.Mutex
+	open     bool
+	failures int
+
+	// closedCtx is cancelled when the breaker opens, which cancels requests
+	// that are still running at that time
+	closedCtx    context.Context
+	cancelClosed context.CancelFunc
+
+	stopCtx context.Context
+	stop    context.CancelFunc
+	probing sync.WaitGroup
+}
+
+// newBreaker creates a breaker, a threshold that is not positive disables it
+func newBreaker(threshold int, latency time.Duration, cooldown time.Duration, probe func(ctx context.Context) error, logger *log.Logger) *breaker {
+	b := &breaker{
+		threshold: threshold,
+		latency:   latency,
+		cooldown:  cooldown,
+		probe:     probe,
+		log:       logger,
+	}
+
+	b.closedCtx, b.cancelClosed = context.WithCancel(context.Background())
+	b.stopCtx, b.stop = context.WithCancel(context.Background())
+	return b
+}
+
+func (b *breaker) enabled() bool {
+	return b.threshold > 0
+}
+
+func (b *breaker) allow() bool {
+	if !b.enabled() {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.open
+}
+
+// guard returns a context for a request to the remote storage, which is
+// cancelled when the breaker opens
+func (b *breaker) guard(ctx context.Context) (context.Context, context.CancelFunc) {
+	if !b.enabled() {
+		return ctx, func() {}
+	}
+
+	b.mu.Lock()
+	closedCtx := b.closedCtx
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(closedCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// record records the result and latency of a request to the remote storage,
+// a missing object or a cancelled request is no failure, a latency of zero
+// is not checked
+func (b *breaker) record(err error, latency time.Duration) {
+	if !b.enabled() || isCancelled(err) {
+		return
+	}
+
+	slow := b.latency > 0 && latency > b.latency
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.open {
+		return
+	}
+
+	switch {
+	case (err == nil || isNotFound(err)) && !slow:
+		b.failures = 0
+		return
+
+	case err == nil || isNotFound(err):
+		b.failures++
+		if b.failures >= b.threshold {
+			b.trip("%d consecutive slow responses, last one took %v", b.failures, latency.Round(time.Millisecond))
+		}
+
+	default:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.trip("%d consecutive failures, last error: %v", b.failures, err)
+		}
+	}
+}
+
+// tripAtStart opens the breaker before the first request, because the remote
+// storage was unreachable when the provider was created
+func (b *breaker) tripAtStart(err error) {
+	if !b.enabled() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trip("%v", err)
+}
+
+// trip opens the breaker, it has to be called with the lock held
+func (b *breaker) trip(format string, args ...any) {
+	b.open = true
+	b.cancelClosed()
+
+	b.log.Printf("remote storage is unavailable, using local cache only for %v: "+format, append([]any{b.cooldown}, args...)...)
+
+	b.probing.Add(1)
+	go b.probeLoop()
+}
+
+// probeLoop probes the remote storage after each cool-down period, until it
+// is available again, or the breaker is stopped
+func (b *breaker) probeLoop() {
+	defer b.probing.Done()
+
+	for {
+		select {
+		case <-time.After(b.cooldown):
+		case <-b.stopCtx.Done():
+			return
+		}
+
+		err := b.probe(b.stopCtx)
+		switch {
+		case b.stopCtx.Err() != nil:
+			return
+
+		case err != nil && !isNotFound(err):
+			b.log.Printf("remote storage is still unavailable, using local cache only for %v: %v", b.cooldown, err)
+			continue
+		}
+
+		b.mu.Lock()
+		b.open, b.failures = false, 0
+		b.closedCtx, b.cancelClosed = context.WithCancel(context.Background())
+		b.mu.Unlock()
+
+		b.log.Printf("remote storage is available again")
+		return
+	}
+}
+
+// close stops probing the remote storage
+func (b *breaker) close() {
+	b.stop()
+	b.probing.Wait()
+	b.cancelClosed()
+}
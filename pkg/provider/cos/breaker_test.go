@@ -0,0 +1,231 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/ibm-cos-sdk-go/aws/awserr"
+	"github.com/IBM/ibm-cos-sdk-go/aws/request"
+)
+
+var errUnavailable = errors.New("unavailable")
+
+func TestBreaker(t *testing.T) {
+	notFound := awserr.NewRequestFailure(awserr.New("NotFound", "", nil), 404, "")
+
+	tests := []struct {
+		name    string
+		results []error
+		latency time.Duration
+		open    bool
+	}{
+		{name: "consecutive failures", results: []error{errUnavailable, errUnavailable, errUnavailable}, open: true},
+		{name: "interrupted failures", results: []error{errUnavailable, errUnavailable, nil, errUnavailable}},
+		{name: "missing objects", results: []error{notFound, notFound, notFound}},
+		{name: "cancelled requests", results: []error{context.Canceled, context.Canceled, context.Canceled}},
+		{name: "slow responses", results: []error{nil, notFound, nil}, latency: time.Second, open: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(3, 100*time.Millisecond, time.Hour, func(context.Context) error { return nil }, log.New(io.Discard, "", 0))
+			defer b.close()
+
+			for _, err := range tt.results {
+				b.record(err, tt.latency)
+			}
+
+			if open := !b.allow(); open != tt.open {
+				t.Errorf("got open %v, want %v", open, tt.open)
+			}
+		})
+	}
+}
+
+func TestBreakerProbes(t *testing.T) {
+	var probes atomic.Int64
+	probe := func(context.Context) error {
+		if probes.Add(1) < 3 {
+			return errUnavailable
+		}
+
+		return nil
+	}
+
+	b := newBreaker(1, 0, 10*time.Millisecond, probe, log.New(io.Discard, "", 0))
+	defer b.close()
+
+	guarded, cancel := b.guard(context.Background())
+	defer cancel()
+
+	b.record(errUnavailable, 0)
+	if b.allow() {
+		t.Fatal("expected breaker to be open")
+	}
+
+	select {
+	case <-guarded.Done():
+	case <-time.After(5 * time.Second):
+		t.Error("expected running request to be cancelled when the breaker opens")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !b.allow() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if !b.allow() || probes.Load() != 3 {
+		t.Errorf("expected breaker to close after the third probe, got %d probes", probes.Load())
+	}
+
+	guarded, cancel = b.guard(context.Background())
+	defer cancel()
+
+	if guarded.Err() != nil {
+		t.Error("expected new requests not to be cancelled after the breaker closed")
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := newBreaker(-1, time.Millisecond, time.Hour, nil, log.New(io.Discard, "", 0))
+	defer b.close()
+
+	for range 10 {
+		b.record(errUnavailable, time.Second)
+	}
+
+	if !b.allow() {
+		t.Error("expected disabled breaker to stay closed")
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	fake, client := newFakeS3(t)
+	fake.fail(http.MethodGet, 2, http.StatusServiceUnavailable)
+
+	var logs bytes.Buffer
+	p := newTestProvider(t, client, Options{MinUploadSize: 1, DownloadRetries: -1, CircuitBreakerThreshold: 2})
+	p.WithLogOutput(&logs)
+
+	for range 2 {
+		if _, _, err := p.Get(context.Background(), "0a"); err == nil {
+			t.Fatal("expected the unavailable remote storage to be reported")
+		}
+	}
+
+	// The remote storage is not used during the cool-down period, so that
+	// neither gets nor uploads are sent
+	if got, _, err := p.Get(context.Background(), "0a"); err != nil || got != "" {
+		t.Errorf("got %q, %v, want miss", got, err)
+	}
+
+	putEntry(t, p, "0b", strings.Repeat("build output ", 1000))
+
+	if gets := fake.count(http.MethodGet, ""); gets != 2 {
+		t.Errorf("got %d gets, want 2", gets)
+	}
+
+	if puts := fake.count(http.MethodPut, ""); puts != 0 {
+		t.Errorf("got %d uploads, want none", puts)
+	}
+
+	for _, want := range []string{"using local cache only for 1m0s: 2 consecutive failures", "uploads to bucket bucket: 0 succeeded, 0 failed, 1 skipped"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected log %q, got %q", want, logs.String())
+		}
+	}
+}
+
+func TestCircuitBreakerUnreachableAtStart(t *testing.T) {
+	fake, client := newFakeS3(t)
+
+	missing := awserr.NewRequestFailure(awserr.New("NoSuchBucket", "NoSuchBucket", nil), http.StatusNotFound, "")
+	if _, err := NewProviderWithBucketCheck(client, "bucket", Options{CacheDir: t.TempDir()}, func() error { return missing }); err == nil {
+		t.Error("expected a missing bucket to fail")
+	}
+
+	unreachable := awserr.New(request.ErrCodeRequestError, "send request failed", errUnavailable)
+	p, err := NewProviderWithBucketCheck(client, "bucket", Options{CacheDir: t.TempDir(), CircuitBreakerCooldown: 500 * time.Millisecond}, func() error { return unreachable })
+	if err != nil {
+		t.Fatalf("expected an unreachable remote storage not to fail, got %v", err)
+	}
+
+	var logs bytes.Buffer
+	p.WithLogOutput(&logs)
+
+	if got, _, err := p.Get(context.Background(), "0a"); err != nil || got != "" {
+		t.Errorf("got %q, %v, want miss", got, err)
+	}
+
+	if gets := fake.count(http.MethodGet, ""); gets != 0 {
+		t.Errorf("got %d gets while the breaker is open, want none", gets)
+	}
+
+	// The remote storage is probed after the cool-down period
+	deadline := time.Now().Add(5 * time.Second)
+	for !p.breaker.allow() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if !p.breaker.allow() {
+		t.Error("expected breaker to close once the remote storage is reachable")
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "remote storage was unreachable at start"; !strings.Contains(logs.String(), want) {
+		t.Errorf("expected log %q, got %q", want, logs.String())
+	}
+}
+
+func TestCircuitBreakerCountsRetries(t *testing.T) {
+	fake, client := newFakeS3(t)
+	fake.fail(http.MethodGet, 10, http.StatusServiceUnavailable)
+
+	p := newTestProvider(t, client, Options{DownloadRetries: 5, CircuitBreakerThreshold: 3})
+
+	// Each attempt counts, so that retries do not hide how often the remote
+	// storage fails, and no retry is sent once the circuit breaker opened
+	_, _, _ = p.Get(context.Background(), "0a")
+	if gets := fake.count(http.MethodGet, ""); gets != 3 {
+		t.Errorf("got %d gets, want 3 until the circuit breaker opens", gets)
+	}
+
+	if p.breaker.allow() {
+		t.Error("expected the circuit breaker to be open")
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
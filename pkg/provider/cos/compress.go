@@ -0,0 +1,107 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const compressionKey = "compression"
+
+// Supported compression codecs
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+var errDecompressionFailed = errors.New("decompression failed")
+
+func validateCompression(codec string, level int) error {
+	switch codec {
+	case "", CompressionNone:
+		return nil
+
+	case CompressionGzip:
+		if level != 0 && (level < gzip.BestSpeed || level > gzip.BestCompression) {
+			return fmt.Errorf("unsupported gzip compression level %d, supported are %d to %d", level, gzip.BestSpeed, gzip.BestCompression)
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported compression %q, supported are %q and %q", codec, CompressionNone, CompressionGzip)
+	}
+}
+
+// compress writes the gzip compressed content of the reader to the writer,
+// level zero uses the default level
+func compress(level int, w io.Writer, r io.Reader) error {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	gz, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(gz, r); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// decompressingReader decompresses content written by compress, errors
+// caused by corrupt content are reported as errDecompressionFailed
+type decompressingReader struct {
+	r  io.Reader
+	gz *gzip.Reader
+}
+
+func (d *decompressingReader) Read(p []byte) (int, error) {
+	if d.gz == nil {
+		gz, err := gzip.NewReader(d.r)
+		if err != nil {
+			return 0, d.wrap(err)
+		}
+
+		d.gz = gz
+	}
+
+	n, err := d.gz.Read(p)
+	return n, d.wrap(err)
+}
+
+func (d *decompressingReader) wrap(err error) error {
+	var corrupt flate.CorruptInputError
+	switch {
+	case errors.Is(err, gzip.ErrHeader), errors.Is(err, gzip.ErrChecksum), errors.As(err, &corrupt):
+		return fmt.Errorf("%w: %v", errDecompressionFailed, err)
+
+	default:
+		return err
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	content := []byte(strings.Repeat("compressible build output ", 10000))
+
+	for _, level := range []int{0, gzip.BestSpeed, gzip.BestCompression} {
+		var buf bytes.Buffer
+		if err := compress(level, &buf, bytes.NewReader(content)); err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+
+		if buf.Len() >= len(content) {
+			t.Errorf("level %d: got %d compressed bytes for %d bytes", level, buf.Len(), len(content))
+		}
+
+		got, err := io.ReadAll(&decompressingReader{r: &buf})
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+
+		if !bytes.Equal(got, content) {
+			t.Errorf("level %d: decompressed content does not match", level)
+		}
+	}
+}
+
+func TestDecompressInvalidContent(t *testing.T) {
+	var buf bytes.Buffer
+	if err := compress(0, &buf, strings.NewReader(strings.Repeat("content", 1000))); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := bytes.Clone(buf.Bytes())
+	corrupt[len(corrupt)-5] ^= 1
+
+	for name, data := range map[string][]byte{
+		"not compressed":   []byte("plain content"),
+		"corrupt checksum": corrupt,
+	} {
+		if _, err := io.ReadAll(&decompressingReader{r: bytes.NewReader(data)}); !errors.Is(err, errDecompressionFailed) {
+			t.Errorf("%s: got %v, want %v", name, err, errDecompressionFailed)
+		}
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	tests := []struct {
+		codec string
+		level int
+		valid bool
+	}{
+		{codec: "", valid: true},
+		{codec: CompressionNone, valid: true},
+		{codec: CompressionGzip, valid: true},
+		{codec: CompressionGzip, level: gzip.BestCompression, valid: true},
+		{codec: CompressionGzip, level: gzip.BestCompression + 1},
+		{codec: CompressionGzip, level: -3},
+		{codec: "zstd"},
+	}
+
+	for _, tt := range tests {
+		if err := validateCompression(tt.codec, tt.level); tt.valid != (err == nil) {
+			t.Errorf("%q level %d: got %v, want valid %v", tt.codec, tt.level, err, tt.valid)
+		}
+	}
+}
+
+func TestCompressedEntries(t *testing.T) {
+	content := strings.Repeat("compressible build output ", 10000)
+
+	tests := []struct {
+		name     string
+		upload   Options
+		download Options
+	}{
+		{
+			name:   "compressed",
+			upload: Options{Compression: CompressionGzip},
+		},
+		{
+			name:     "compressed and encrypted",
+			upload:   Options{Compression: CompressionGzip, EncryptionKey: testEncryptionKey},
+			download: Options{EncryptionKey: testEncryptionKey},
+		},
+		{
+			name:     "uncompressed entry read with compression configured",
+			download: Options{Compression: CompressionGzip},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+
+			tt.upload.MinUploadSize = 1
+			objectId := putEntry(t, newTestProvider(t, client, tt.upload), "0a", content)
+
+			obj := fake.content(t, "0a")
+			if want := tt.upload.Compression; obj.metadata[compressionKey] != want {
+				t.Errorf("got compression metadata %q, want %q", obj.metadata[compressionKey], want)
+			}
+
+			if tt.upload.Compression != "" && len(obj.body) >= len(content) {
+				t.Errorf("got %d uploaded bytes for %d bytes of content", len(obj.body), len(content))
+			}
+
+			got, _, err := newTestProvider(t, client, tt.download).Get(context.Background(), "0a")
+			if err != nil || got != objectId {
+				t.Errorf("got %q, %v, want hit", got, err)
+			}
+		})
+	}
+}
+
+func TestCorruptCompressedEntries(t *testing.T) {
+	fake, client := newFakeS3(t)
+
+	content := strings.Repeat("compressible build output ", 10000)
+
+	var buf bytes.Buffer
+	if err := compress(0, &buf, strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := bytes.Clone(buf.Bytes())
+	corrupt[len(corrupt)/2] ^= 0xff
+
+	metadata := func(codec string) map[string]string {
+		return map[string]string{
+			objectIdKey:    outputId(content),
+			sizeKey:        strconv.Itoa(len(content)),
+			compressionKey: codec,
+		}
+	}
+
+	fake.put("action/0a", corrupt, metadata(CompressionGzip))
+	fake.put("action/0b", buf.Bytes(), metadata("zstd"))
+
+	p := newTestProvider(t, client, Options{})
+	for _, actionId := range []string{"0a", "0b"} {
+		if got, _, err := p.Get(context.Background(), actionId); err != nil || got != "" {
+			t.Errorf("%s: got %q, %v, want miss", actionId, got, err)
+		}
+	}
+
+	if _, found := fake.object("action/0a"); found {
+		t.Error("expected corrupt action object to be deleted")
+	}
+
+	if _, found := fake.object("action/0b"); !found {
+		t.Error("expected action object with unsupported compression to be kept")
+	}
+}
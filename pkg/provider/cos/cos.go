@@ -21,41 +21,192 @@
 package cos
 
 import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"log"
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IBM/ibm-cos-sdk-go/aws"
 	"github.com/IBM/ibm-cos-sdk-go/aws/credentials"
+	"github.com/IBM/ibm-cos-sdk-go/aws/request"
 	"github.com/IBM/ibm-cos-sdk-go/aws/session"
 	"github.com/IBM/ibm-cos-sdk-go/service/s3"
 	"github.com/homeport/go-cache-prog/pkg/cache"
+	"github.com/homeport/go-cache-prog/pkg/provider/internal/transfer"
 	"github.com/homeport/go-cache-prog/pkg/provider/local"
+	"github.com/homeport/go-cache-prog/pkg/singleflight"
 )
 
 const DefaultMinUploadSize = 2048
 const DefaultTimeout = 5 * time.Second
 const DefaultMaxRetries = 2
+const DefaultDownloadRetries = 2
+
+const initialDownloadBackoff = 100 * time.Millisecond
 
 const objectIdKey = "objectid"
 const sizeKey = "size"
 
+// objectKeyKey is set on action records of the content-addressed layout, it
+// refers to the object with the content
+const objectKeyKey = "objectkey"
+
+// Layouts of the bucket
+const (
+	// LayoutObject stores small action records that refer to content-addressed
+	// objects, so that identical content is only stored once. Previous
+	// versions read the empty action records as empty content, so it has to
+	// be enabled explicitly once all clients are updated.
+	LayoutObject = "object"
+
+	// LayoutAction stores the content as part of the action object, which is
+	// the layout of previous versions and the default
+	LayoutAction = "action"
+)
+
 type provider struct {
-	config Config
-	client *s3.S3
+	options Options
+	bucket  string
+	client  *s3.S3
+
+	localProvider localTier
+	uploads       *transfer.UploadQueue
+	aead          cipher.AEAD
+
+	// Pending uploads of previous runs are read from the journal when the
+	// provider is created, and replayed in the background, so that the
+	// first get or put does not wait for the local cache directory
+	journal    *journal
+	pending    map[string]string
+	pendingErr error
+	replayOnce sync.Once
+	replaying  sync.WaitGroup
+
+	log      *log.Logger
+	repaired atomic.Int64
+	rejected atomic.Int64
+
+	// Entries of the manifest are prefetched in the background, gets of
+	// entries that are being prefetched share the download
+	manifest     *manifest
+	prefetchOnce sync.Once
+	prefetchCtx  context.Context
+	stopPrefetch context.CancelFunc
+	prefetching  sync.WaitGroup
+	fetches      singleflight.Group[fetched]
+
+	// The breaker starts open, if the remote storage was unreachable when
+	// the provider was created, which is reported with the log output
+	breaker         *breaker
+	unavailable     error
+	unavailableOnce sync.Once
+
+	// Results of gets from the bucket, reported when closing the provider
+	hits            atomic.Int64
+	misses          atomic.Int64
+	transientErrors atomic.Int64
+	permanentErrors atomic.Int64
+}
+
+// fetched is an entry that was downloaded into the local cache directory
+type fetched struct {
+	objectId string
+	diskpath string
+}
 
-	localProvider cache.Provider
-	uploadGroup   *sync.WaitGroup
+// localTier is the local cache directory, which is used as first tier
+type localTier interface {
+	cache.Provider
+	cache.ObjectLookup
 }
 
 type Config struct {
-	Cos           Cos    `json:"cos"`
+	Cos Cos `json:"cos"`
+	Options
+}
+
+// Options are the settings of the cache that do not depend on the remote
+// storage, they are shared with the other remote providers
+type Options struct {
 	CacheDir      string `json:"cache_dir"`
 	MinUploadSize int64  `json:"min_upload_size"`
+	Fsync         bool   `json:"fsync"`
+
+	VerifyPuts         bool `json:"verify_puts"`
+	SkipDownloadVerify bool `json:"skip_download_verify"`
+
+	MaxSize int64         `json:"max_size"`
+	MaxAge  time.Duration `json:"max_age"`
+
+	// ReadOnly stores entries in the local cache directory only and never
+	// writes to, or deletes from, the remote storage, e.g. for builds of
+	// untrusted changes that must not be able to poison the shared cache
+	ReadOnly bool `json:"read_only"`
+
+	// SigningKey is a secret shared by the trusted writers of the cache, if
+	// set, uploads are signed and downloads without valid signature are
+	// rejected. Downloads are always verified when signing is used.
+	SigningKey string `json:"signing_key"`
+
+	// EncryptionKey is a hex or base64 encoded 256 bit key, if set, content
+	// is encrypted before it is uploaded, the local cache directory keeps
+	// the content unencrypted
+	EncryptionKey string `json:"encryption_key"`
+
+	// Compression of uploaded content, the codec is stored as metadata, so
+	// that uncompressed content uploaded before is still usable
+	Compression      string `json:"compression"`
+	CompressionLevel int    `json:"compression_level"`
+
+	// Layout of uploads, entries of both layouts are always read, so that
+	// the previous layout can be used while not all clients are updated
+	Layout string `json:"layout"`
+
+	// Uploads run in the background using a limited number of concurrent
+	// uploads, the drop policy applies when the backlog is full. Transient
+	// errors are retried, a negative number of retries disables retrying.
+	UploadConcurrency int    `json:"upload_concurrency"`
+	UploadBacklog     int    `json:"upload_backlog"`
+	UploadRetries     int    `json:"upload_retries"`
+	UploadDropPolicy  string `json:"upload_drop_policy"`
+
+	// DownloadRetries is the number of retries of downloads that failed
+	// with a transient error, a negative number disables retrying
+	DownloadRetries int `json:"download_retries"`
+
+	// CircuitBreakerThreshold is the number of consecutive failures or slow
+	// responses after which only the local cache directory is used for the
+	// cool-down period, before the remote storage is probed again. Responses
+	// are slow when they take longer than CircuitBreakerLatency. A negative
+	// threshold disables the circuit breaker.
+	CircuitBreakerThreshold int           `json:"circuit_breaker_threshold"`
+	CircuitBreakerLatency   time.Duration `json:"circuit_breaker_latency"`
+	CircuitBreakerCooldown  time.Duration `json:"circuit_breaker_cooldown"`
+
+	// Objects of at least the multipart threshold are uploaded using multipart
+	// uploads and downloaded using ranged requests, transferring up to the
+	// transfer concurrency parts of the part size in parallel. A negative
+	// threshold disables multipart transfers.
+	MultipartThreshold  int64 `json:"multipart_threshold"`
+	PartSize            int64 `json:"part_size"`
+	TransferConcurrency int   `json:"transfer_concurrency"`
+
+	// The action ids used in a session are recorded in a manifest, either a
+	// local file, or an object in the bucket under manifest/<key>, e.g. keyed
+	// by branch. The entries of the manifest of the previous session are
+	// prefetched in the background, using the prefetch concurrency.
+	ManifestFile        string `json:"manifest_file"`
+	ManifestKey         string `json:"manifest_key"`
+	PrefetchConcurrency int    `json:"prefetch_concurrency"`
 }
 
 type Cos struct {
@@ -66,40 +217,49 @@ type Cos struct {
 	AccessKeyID     string `json:"access_key_id"`
 	SecretAccessKey string `json:"secret_access_key"`
 
-	Timeout    time.Duration `json:"timeout"`
-	MaxRetries int           `json:"max_retries"`
+	// Credentials used instead in read-only mode, so that read-only jobs do
+	// not need write-capable credentials
+	ReadOnlyAccessKeyID     string `json:"read_only_access_key_id"`
+	ReadOnlySecretAccessKey string `json:"read_only_secret_access_key"`
+
+	// Timeout of requests, requests with a body may take longer, as long as
+	// the body is transferred at least at the minimum transfer rate (bytes
+	// per second)
+	Timeout         time.Duration `json:"timeout"`
+	MinTransferRate int64         `json:"min_transfer_rate"`
+
+	// MaxRetries of the SDK, downloads and uploads are not retried by the
+	// SDK, but by the provider using the download and upload retries
+	MaxRetries int `json:"max_retries"`
 }
 
-var _ cache.Provider = &provider{}
+var _ cache.ContextProvider = &provider{}
+var _ cache.ObjectLookup = &provider{}
 
 func (p *provider) actionKey(actionId string) string {
 	return "action/" + actionId
 }
 
+func (p *provider) objectKey(objectId string) string {
+	return "object/" + objectId
+}
+
 func (p *provider) KnownCommands() []string {
 	return []string{"get", "put", "close"}
 }
 
 func NewProvider(config Config) (*provider, error) {
-	if config.CacheDir == "" {
-		return nil, fmt.Errorf("cache directory cannot be empty")
-	}
-
 	if config.Cos.Timeout == 0 {
 		config.Cos.Timeout = DefaultTimeout
 	}
 
-	if config.MinUploadSize <= 0 {
-		config.MinUploadSize = DefaultMinUploadSize
-	}
-
 	if config.Cos.MaxRetries == 0 {
 		config.Cos.MaxRetries = DefaultMaxRetries
 	}
 
-	localProvider, err := local.NewProvider(config.CacheDir)
-	if err != nil {
-		return nil, err
+	accessKeyID, secretAccessKey := config.Cos.AccessKeyID, config.Cos.SecretAccessKey
+	if config.ReadOnly && config.Cos.ReadOnlyAccessKeyID != "" {
+		accessKeyID, secretAccessKey = config.Cos.ReadOnlyAccessKeyID, config.Cos.ReadOnlySecretAccessKey
 	}
 
 	session, err := session.NewSession()
@@ -113,51 +273,251 @@ func NewProvider(config Config) (*provider, error) {
 			WithEndpoint(config.Cos.Endpoint).
 			WithRegion(config.Cos.Region).
 			WithCredentials(credentials.NewStaticCredentialsFromCreds(credentials.Value{
-				AccessKeyID:     config.Cos.AccessKeyID,
-				SecretAccessKey: config.Cos.SecretAccessKey,
+				AccessKeyID:     accessKeyID,
+				SecretAccessKey: secretAccessKey,
 			})).
 			WithLowerCaseHeaderMaps(true).
 			WithS3ForcePathStyle(true).
-			WithHTTPClient(&http.Client{
-				Timeout: config.Cos.Timeout,
-			}).
+			WithHTTPClient(transfer.NewHTTPClient(config.Cos.Timeout, config.Cos.MinTransferRate)).
 			WithMaxRetries(config.Cos.MaxRetries),
 	)
 
-	listBucketResp, err := client.ListBuckets(&s3.ListBucketsInput{})
+	return NewProviderWithBucketCheck(client, config.Cos.Bucket, config.Options, func() error {
+		listBucketResp, err := client.ListBuckets(&s3.ListBucketsInput{})
+		if err != nil {
+			return err
+		}
+
+		for _, bucket := range listBucketResp.Buckets {
+			if bucket.Name != nil && config.Cos.Bucket == *bucket.Name {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("failed to find bucket %q in COS", config.Cos.Bucket)
+	})
+}
+
+// NewProviderWithBucketCheck creates a provider like NewProviderWithClient,
+// after checking the bucket. A missing bucket fails, but an unreachable
+// remote storage does not, the provider then starts with the circuit breaker
+// open and probes the remote storage in the background.
+func NewProviderWithBucketCheck(client *s3.S3, bucket string, options Options, check func() error) (*provider, error) {
+	err := check()
+	if err != nil && !isTransient(err) {
+		return nil, err
+	}
+
+	p, perr := NewProviderWithClient(client, bucket, options)
+	if perr != nil {
+		return nil, perr
+	}
+
 	if err != nil {
+		p.unavailable = err
+		p.breaker.tripAtStart(err)
+	}
+
+	return p, nil
+}
+
+// NewProviderWithClient creates a provider that uses the bucket of the given
+// S3 compatible client as remote storage. The client has to be configured
+// with lower case header maps, since metadata keys are looked up in lower
+// case.
+func NewProviderWithClient(client *s3.S3, bucket string, options Options) (*provider, error) {
+	if options.CacheDir == "" {
+		return nil, fmt.Errorf("cache directory cannot be empty")
+	}
+
+	if bucket == "" {
+		return nil, fmt.Errorf("bucket cannot be empty")
+	}
+
+	if options.MinUploadSize <= 0 {
+		options.MinUploadSize = DefaultMinUploadSize
+	}
+
+	if err := validateCompression(options.Compression, options.CompressionLevel); err != nil {
 		return nil, err
 	}
 
-	var bucketFound bool
-	for _, bucket := range listBucketResp.Buckets {
-		if config.Cos.Bucket == *bucket.Name {
-			bucketFound = true
-			break
+	if options.DownloadRetries == 0 {
+		options.DownloadRetries = DefaultDownloadRetries
+	}
+
+	if options.CircuitBreakerThreshold == 0 {
+		options.CircuitBreakerThreshold = DefaultCircuitBreakerThreshold
+	}
+
+	if options.CircuitBreakerLatency == 0 {
+		options.CircuitBreakerLatency = DefaultCircuitBreakerLatency
+	}
+
+	if options.CircuitBreakerCooldown <= 0 {
+		options.CircuitBreakerCooldown = DefaultCircuitBreakerCooldown
+	}
+
+	// Parts of multipart uploads are retried by the provider, not by the
+	// upload queue
+	if options.UploadRetries == 0 {
+		options.UploadRetries = DefaultUploadRetries
+	}
+
+	if options.MultipartThreshold == 0 {
+		options.MultipartThreshold = DefaultMultipartThreshold
+	}
+
+	switch {
+	case options.PartSize == 0:
+		options.PartSize = DefaultPartSize
+
+	case options.PartSize < MinPartSize:
+		return nil, fmt.Errorf("part size of %d bytes is below the minimum of %d bytes", options.PartSize, MinPartSize)
+	}
+
+	if options.TransferConcurrency <= 0 {
+		options.TransferConcurrency = DefaultTransferConcurrency
+	}
+
+	if options.ManifestFile != "" && options.ManifestKey != "" {
+		return nil, fmt.Errorf("manifest file and manifest key cannot be used together")
+	}
+
+	if options.PrefetchConcurrency <= 0 {
+		options.PrefetchConcurrency = DefaultPrefetchConcurrency
+	}
+
+	switch options.Layout {
+	case "":
+		options.Layout = LayoutAction
+
+	case LayoutObject, LayoutAction:
+
+	default:
+		return nil, fmt.Errorf("unsupported layout %q, supported are %q and %q", options.Layout, LayoutObject, LayoutAction)
+	}
+
+	var aead cipher.AEAD
+	if options.EncryptionKey != "" {
+		var err error
+		if aead, err = parseEncryptionKey(options.EncryptionKey); err != nil {
+			return nil, err
 		}
 	}
 
-	if !bucketFound {
-		return nil, fmt.Errorf("failed to find bucket %q in COS", config.Cos.Bucket)
+	localProvider, err := local.NewProvider(options.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	journal, err := newJournal(options.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending map[string]string
+	var pendingErr error
+	if !options.ReadOnly {
+		pending, pendingErr = journal.entries()
 	}
 
-	return &provider{
+	logger := log.New(io.Discard, "", log.LstdFlags)
+
+	localProvider.
+		WithSync(options.Fsync).
+		WithVerify(options.VerifyPuts).
+		WithMaxSize(options.MaxSize).
+		WithMaxAge(options.MaxAge).
+		WithLogger(logger)
+
+	uploads, err := transfer.NewUploadQueue(transfer.QueueOptions{
+		Concurrency: options.UploadConcurrency,
+		Backlog:     options.UploadBacklog,
+		DropPolicy:  options.UploadDropPolicy,
+		Retries:     options.UploadRetries,
+		Transient:   isTransient,
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &provider{
 		client:        client,
-		config:        config,
+		options:       options,
+		bucket:        bucket,
 		localProvider: localProvider,
-		uploadGroup:   &sync.WaitGroup{},
-	}, nil
+		journal:       journal,
+		pending:       pending,
+		pendingErr:    pendingErr,
+		uploads:       uploads,
+		aead:          aead,
+		log:           logger,
+	}
+
+	if options.ManifestFile != "" || options.ManifestKey != "" {
+		p.manifest = newManifest()
+	}
+
+	p.prefetchCtx, p.stopPrefetch = context.WithCancel(context.Background())
+	p.breaker = newBreaker(options.CircuitBreakerThreshold, options.CircuitBreakerLatency, options.CircuitBreakerCooldown, p.probe, logger)
+	return p, nil
+}
+
+// probe checks whether the remote storage is available, a missing object is
+// an answer of the remote storage as well
+func (p *provider) probe(ctx context.Context) error {
+	_, err := p.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: &p.bucket,
+		Key:    aws.String(p.actionKey("0")),
+	})
+
+	return err
+}
+
+// WithLogOutput configures where the provider, including its local cache
+// directory, writes its log output to
+func (p *provider) WithLogOutput(w io.Writer) *provider {
+	p.SetLogOutput(w)
+	return p
+}
+
+// SetLogOutput is like WithLogOutput, but can be used by wrapping providers
+func (p *provider) SetLogOutput(w io.Writer) {
+	p.log.SetOutput(w)
+
+	// The log output is configured after the provider is created, so an
+	// unreachable remote storage at that time is reported here
+	p.unavailableOnce.Do(func() {
+		if p.unavailable != nil {
+			p.log.Printf("remote storage was unreachable at start, using local cache only until it is available again: %v", p.unavailable)
+		}
+	})
 }
 
 func lookUpObjectId(metadata map[string]*string) (string, bool) {
 	val, found := metadata[objectIdKey]
-	if !found || val == nil {
+	if !found || val == nil || *val == "" {
+		return "", false
+	}
+
+	// The object id ends up as a file name in the local cache directory
+	if _, err := hex.DecodeString(*val); err != nil {
 		return "", false
 	}
 
 	return *val, true
 }
 
+func lookUp(metadata map[string]*string, key string) string {
+	val, found := metadata[key]
+	if !found || val == nil {
+		return ""
+	}
+
+	return *val
+}
+
 func lookUpSize(metadata map[string]*string) (int64, bool) {
 	val, found := metadata[sizeKey]
 	if !found || val == nil {
@@ -172,7 +532,14 @@ func lookUpSize(metadata map[string]*string) (int64, bool) {
 	return size, true
 }
 
-func (p *provider) Get(actionId string) (string, string, error) {
+func (p *provider) Get(ctx context.Context, actionId string) (string, string, error) {
+	p.replayJournalInBackground()
+	p.Prefetch()
+
+	if p.manifest != nil {
+		p.manifest.record(actionId)
+	}
+
 	objectId, diskpath, err := p.localProvider.Get(actionId)
 	if err != nil {
 		return failure(err)
@@ -184,47 +551,267 @@ func (p *provider) Get(actionId string) (string, string, error) {
 
 	// --- --- ---
 
-	obj := &s3.GetObjectInput{
-		Bucket: &p.config.Cos.Bucket,
-		Key:    ptr(p.actionKey(actionId)),
+	return p.getRemote(ctx, actionId)
+}
+
+// getRemote downloads the entry from the bucket into the local cache
+// directory, concurrent downloads of the same entry, e.g. by a get and the
+// prefetch, share one download
+func (p *provider) getRemote(ctx context.Context, actionId string) (string, string, error) {
+	entry, err, _ := p.fetches.Do(actionId, func() (fetched, error) {
+		objectId, diskpath, err := p.fetch(ctx, actionId)
+		return fetched{objectId: objectId, diskpath: diskpath}, err
+	})
+
+	return entry.objectId, entry.diskpath, err
+}
+
+func (p *provider) fetch(ctx context.Context, actionId string) (string, string, error) {
+	if !p.breaker.allow() {
+		return notFound()
 	}
 
-	res, err := p.client.GetObject(obj)
-	if err != nil {
+	// Requests to the remote storage are cancelled when the circuit breaker
+	// opens, the build continues with the local cache directory only
+	ctx, cancel := p.breaker.guard(ctx)
+	defer cancel()
+
+	res, err := p.getObject(ctx, p.actionKey(actionId))
+	switch {
+	case errors.Is(err, errRemoteDisabled):
+		return notFound()
+
+	case err != nil:
+		return failure(err)
+
+	case res == nil:
+		p.misses.Add(1)
 		return notFound()
 	}
+	defer func() { _ = res.Body.Close() }()
 
 	objectId, found := lookUpObjectId(res.Metadata)
 	if !found {
-		// TODO: delete invalid action entry
+		p.repair(ctx, p.actionKey(actionId), "missing or invalid %s metadata", objectIdKey)
 		return notFound()
 	}
 
 	size, found := lookUpSize(res.Metadata)
 	if !found {
-		// TODO: delete invalid action entry
+		p.repair(ctx, p.actionKey(actionId), "missing or invalid %s metadata", sizeKey)
 		return notFound()
 	}
 
-	diskpath, err = p.localProvider.Put(actionId, objectId, res.Body)
-	if err != nil {
+	// Entries without valid signature are not deleted, since they might be
+	// signed with another key, a trusted writer replaces them on the next put
+	if p.options.SigningKey != "" {
+		if err := verifySignature(p.options.SigningKey, res.Metadata, actionId, objectId, size); err != nil {
+			p.rejected.Add(1)
+			p.log.Printf("rejected action object %s: %v", p.actionKey(actionId), err)
+			return notFound()
+		}
+	}
+
+	// Action records point to the content-addressed object, action objects
+	// of the previous layout contain the content themselves
+	var (
+		contentKey = p.actionKey(actionId)
+		object     = res
+	)
+
+	if lookUp(res.Metadata, objectKeyKey) != "" {
+		contentKey = p.objectKey(objectId)
+
+		obj, err := p.getObject(ctx, contentKey)
+		switch {
+		case errors.Is(err, errRemoteDisabled):
+			return notFound()
+
+		case err != nil:
+			return failure(err)
+
+		case obj == nil:
+			p.repair(ctx, p.actionKey(actionId), "referenced object %s does not exist", contentKey)
+			return notFound()
+		}
+		defer func() { _ = obj.Body.Close() }()
+
+		object = obj
+	}
+
+	var content io.Reader = object.Body
+	metadata := object.Metadata
+
+	// Large objects are downloaded using parallel ranged requests first
+	if p.multipart(aws.Int64Value(object.ContentLength)) {
+		downloaded, err := p.download(ctx, contentKey, object)
+		p.breaker.record(err, 0)
+		if err != nil {
+			return failure(fmt.Errorf("failed to download %s: %w", contentKey, p.remoteError(err)))
+		}
+		defer func() { _ = downloaded.Close() }()
+
+		content = downloaded
+	}
+
+	var body io.Reader = content
+	switch scheme := lookUp(metadata, encryptionKey); {
+	case scheme == "":
+
+	case p.aead == nil:
+		p.log.Printf("skipping encrypted object %s, no encryption key is configured", contentKey)
+		return notFound()
+
+	case scheme != encryptionScheme:
+		p.log.Printf("skipping object %s with unsupported encryption %q", contentKey, scheme)
 		return notFound()
+
+	default:
+		body = newDecryptingReader(p.aead, content)
 	}
 
-	fi, err := os.Stat(diskpath)
-	if err != nil {
-		return failure(err)
+	switch codec := lookUp(metadata, compressionKey); codec {
+	case "", CompressionNone:
+
+	case CompressionGzip:
+		body = &decompressingReader{r: body}
+
+	default:
+		p.log.Printf("skipping object %s with unsupported compression %q", contentKey, codec)
+		return notFound()
 	}
 
-	if fi.Size() != size {
-		// TODO: delete invalid action entry
+	// The size and content are checked while downloading, so that a mismatch
+	// fails the write into the local cache directory and leaves no local
+	// entry behind. The content is checked first, so that a size mismatch
+	// of valid content is only caused by the action record.
+	if !p.options.SkipDownloadVerify || p.options.SigningKey != "" {
+		body = cache.NewVerifyingReader(body, objectId)
+	}
+	body = &sizeCheckReader{r: body, size: size}
+
+	diskpath, err := p.localProvider.Put(actionId, objectId, body)
+	switch {
+	case errors.Is(err, cache.ErrOutputMismatch), errors.Is(err, errDecompressionFailed):
+		// An invalid content-addressed object is removed as well, otherwise
+		// it would be referenced again by the next upload
+		if contentKey != p.actionKey(actionId) {
+			p.repair(ctx, contentKey, "%v", err)
+		}
+
+		p.repair(ctx, p.actionKey(actionId), "%v", err)
 		return notFound()
+
+	case errors.Is(err, errSizeMismatch):
+		// Only the action record is removed, a content-addressed object
+		// might be shared with other actions whose records are valid
+		p.repair(ctx, p.actionKey(actionId), "%v", err)
+		return notFound()
+
+	case errors.Is(err, errDecryptionFailed):
+		// Not repaired, since the object might be encrypted with another key
+		p.log.Printf("failed to decrypt object %s: %v", contentKey, err)
+		return notFound()
+
+	case err != nil:
+		return failure(fmt.Errorf("failed to download %s: %w", contentKey, err))
 	}
 
+	p.hits.Add(1)
 	return objectId, diskpath, nil
 }
 
-func (p *provider) Put(actionId string, objectId string, body io.Reader) (string, error) {
+// getObject gets the object from the bucket, transient errors are retried.
+// A missing object is reported without error and without output. Once the
+// circuit breaker is open, no further attempt is made and errRemoteDisabled
+// is returned.
+func (p *provider) getObject(ctx context.Context, key string) (*s3.GetObjectOutput, error) {
+	var res *s3.GetObjectOutput
+	err := retry(ctx, p.options.DownloadRetries, initialDownloadBackoff, func() error {
+		// Each attempt counts for the circuit breaker, so that retries do
+		// not hide how often the remote storage fails
+		if !p.breaker.allow() {
+			return errRemoteDisabled
+		}
+
+		start := time.Now()
+
+		var err error
+		res, err = p.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: &p.bucket,
+			Key:    &key,
+		}, withoutSDKRetries)
+
+		p.breaker.record(err, time.Since(start))
+		return err
+	})
+
+	switch {
+	case err == nil:
+		return res, nil
+
+	case isNotFound(err):
+		return nil, nil
+
+	case errors.Is(err, errRemoteDisabled):
+		return nil, err
+
+	default:
+		return nil, p.remoteError(err)
+	}
+}
+
+// remoteError counts and classifies an error of the remote storage, errors
+// of cancelled requests are returned as they are
+func (p *provider) remoteError(err error) error {
+	switch {
+	case isCancelled(err):
+		return err
+
+	case isTransient(err):
+		p.transientErrors.Add(1)
+
+	default:
+		p.permanentErrors.Add(1)
+	}
+
+	return classify(err)
+}
+
+// repair deletes an invalid object from the bucket, so that it is not
+// downloaded over and over again, and instead is replaced by the next
+// upload of the action
+func (p *provider) repair(ctx context.Context, key string, format string, args ...any) {
+	reason := fmt.Sprintf(format, args...)
+
+	if p.options.ReadOnly {
+		p.log.Printf("skipping invalid object %s in read-only mode: %s", key, reason)
+		return
+	}
+
+	_, err := p.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: &p.bucket,
+		Key:    &key,
+	})
+
+	if err != nil {
+		p.log.Printf("failed to delete invalid object %s (%s): %v", key, reason, err)
+		return
+	}
+
+	p.repaired.Add(1)
+	p.log.Printf("deleted invalid object %s: %s", key, reason)
+}
+
+// Object looks up the object in the local cache directory only
+func (p *provider) Object(objectId string) (string, error) {
+	return p.localProvider.Object(objectId)
+}
+
+func (p *provider) Put(ctx context.Context, actionId string, objectId string, body io.Reader) (string, error) {
+	p.replayJournalInBackground()
+	p.Prefetch()
+
 	diskpath, err := p.localProvider.Put(actionId, objectId, body)
 	if err != nil {
 		return "", err
@@ -238,50 +825,372 @@ func (p *provider) Put(actionId string, objectId string, body io.Reader) (string
 	}
 
 	size := fi.Size()
-	if size < p.config.MinUploadSize {
+	if size < p.options.MinUploadSize || p.options.ReadOnly {
 		return diskpath, nil
 	}
 
-	// Ignore upload failures to COS and just rely on the local object
-	p.uploadGroup.Add(1)
-	go func() {
-		defer p.uploadGroup.Done()
+	// Upload failures are only logged, the local object is still usable,
+	// pending uploads are recorded in the journal to be replayed later
+	if err := p.journal.add(actionId, objectId); err != nil {
+		p.log.Printf("failed to record upload of action %s in journal: %v", actionId, err)
+	}
 
-		file, err := os.Open(diskpath) // #nosec G304 - provider takes care of filepath clean call
+	p.uploads.Enqueue(p.uploadJob(actionId, objectId, diskpath, size))
+
+	return diskpath, nil
+}
+
+func (p *provider) uploadJob(actionId string, objectId string, diskpath string, size int64) transfer.Job {
+	return transfer.Job{
+		ActionId: actionId,
+		Upload: func(ctx context.Context) error {
+			if !p.breaker.allow() {
+				return errRemoteDisabled
+			}
+
+			guarded, cancel := p.breaker.guard(ctx)
+			defer cancel()
+
+			// Uploads take as long as their size requires, only their
+			// result is recorded, not their latency
+			err := p.upload(guarded, actionId, objectId, diskpath, size)
+			if err != nil && guarded.Err() != nil && ctx.Err() == nil {
+				return errRemoteDisabled
+			}
+
+			p.breaker.record(err, 0)
+			return err
+		},
+		Done: func(err error) {
+			// Uploads that failed with a transient error are replayed later
+			if err == nil || !isTransient(err) {
+				p.journal.remove(actionId, objectId)
+			}
+		},
+	}
+}
+
+// replayJournalInBackground replays the journal with the first get or put,
+// without waiting for it
+func (p *provider) replayJournalInBackground() {
+	p.replayOnce.Do(func() {
+		p.replaying.Add(1)
+		go func() {
+			defer p.replaying.Done()
+			p.replayJournal()
+		}()
+	})
+}
+
+// ReplayJournal adds the pending uploads recorded in the journal by previous
+// runs to the upload backlog, it returns the number of replayed uploads. Only
+// the first call has an effect, and none if the first get or put already
+// replayed the journal in the background.
+func (p *provider) ReplayJournal() int {
+	var replayed int
+	p.replayOnce.Do(func() {
+		replayed = p.replayJournal()
+	})
+
+	return replayed
+}
+
+func (p *provider) replayJournal() int {
+	if p.pendingErr != nil {
+		p.log.Printf("failed to read journal: %v", p.pendingErr)
+		return 0
+	}
+
+	var replayed int
+	for actionId, objectId := range p.pending {
+		// Entries that are no longer in the local cache directory, e.g.
+		// because it was trimmed, cannot be uploaded anymore
+		localObjectId, diskpath, err := p.localProvider.Get(actionId)
+		if err != nil || localObjectId != objectId || diskpath == "" {
+			p.journal.remove(actionId, objectId)
+			continue
+		}
+
+		fi, err := os.Stat(diskpath)
 		if err != nil {
-			return
+			continue
 		}
-		defer func() { _ = file.Close() }()
 
-		_, _ = p.client.PutObject(&s3.PutObjectInput{
-			Bucket: &p.config.Cos.Bucket,
-			Key:    ptr(p.actionKey(actionId)),
+		// Entries that do not fit into the backlog stay in the journal
+		if !p.uploads.TryEnqueue(p.uploadJob(actionId, objectId, diskpath, fi.Size())) {
+			break
+		}
 
-			Metadata: map[string]*string{
-				objectIdKey: &objectId,
-				sizeKey:     ptr(strconv.FormatInt(size, 10)),
-			},
+		replayed++
+	}
 
-			Body:          file,
-			ContentLength: &size,
-		})
-	}()
+	if replayed > 0 {
+		p.log.Printf("replaying %d pending uploads from journal", replayed)
+	}
 
-	return diskpath, nil
+	return replayed
 }
 
-func (p *provider) Close() error {
-	// TODO Implement more close stuff?
+// upload stores the action in the bucket, with the content-addressed layout
+// the object is only uploaded when it does not exist yet, and always before
+// the action record, so that a record never references a missing object
+func (p *provider) upload(ctx context.Context, actionId string, objectId string, diskpath string, size int64) error {
+	record := map[string]*string{
+		objectIdKey: &objectId,
+		sizeKey:     ptr(strconv.FormatInt(size, 10)),
+	}
+
+	if p.options.SigningKey != "" {
+		record[signatureKey] = ptr(sign(p.options.SigningKey, actionId, objectId, size))
+	}
+
+	if p.options.Layout == LayoutAction {
+		return p.uploadContent(ctx, p.actionKey(actionId), diskpath, record)
+	}
+
+	reusable, err := p.reusable(ctx, p.objectKey(objectId))
+	if err != nil {
+		return err
+	}
+
+	if !reusable {
+		metadata := map[string]*string{
+			objectIdKey: &objectId,
+			sizeKey:     ptr(strconv.FormatInt(size, 10)),
+		}
+
+		if err := p.uploadContent(ctx, p.objectKey(objectId), diskpath, metadata); err != nil {
+			return err
+		}
+	}
+
+	record[objectKeyKey] = ptr(p.objectKey(objectId))
+	_, err = p.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: &p.bucket,
+		Key:    ptr(p.actionKey(actionId)),
+
+		Metadata: record,
+
+		Body:          bytes.NewReader(nil),
+		ContentLength: ptr(int64(0)),
+	}, withoutSDKRetries)
+
+	return err
+}
+
+// exists checks whether the object exists in the bucket
+func (p *provider) exists(ctx context.Context, key string, opts ...request.Option) (bool, error) {
+	_, err := p.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: &p.bucket,
+		Key:    &key,
+	}, opts...)
+
+	switch {
+	case err == nil:
+		return true, nil
+
+	case isNotFound(err):
+		return false, nil
+
+	default:
+		return false, err
+	}
+}
+
+// reusable checks whether the object exists in the bucket and is stored with
+// the configured compression and encryption, otherwise the object is uploaded
+// again, so that an unencrypted object is not referenced although encryption
+// is configured, and readers with the same configuration can use it
+func (p *provider) reusable(ctx context.Context, key string) (bool, error) {
+	head, err := p.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: &p.bucket,
+		Key:    &key,
+	}, withoutSDKRetries)
+
+	switch {
+	case isNotFound(err):
+		return false, nil
+
+	case err != nil:
+		return false, err
+	}
+
+	compression, scheme := lookUp(head.Metadata, compressionKey), lookUp(head.Metadata, encryptionKey)
+	if compression == "" {
+		compression = CompressionNone
+	}
+
+	wantCompression, wantScheme := CompressionNone, ""
+	if p.compressed() {
+		wantCompression = p.options.Compression
+	}
+
+	if p.aead != nil {
+		wantScheme = encryptionScheme
+	}
+
+	return compression == wantCompression && scheme == wantScheme, nil
+}
+
+// uploadContent uploads the file, compressed and encrypted if configured
+func (p *provider) uploadContent(ctx context.Context, key string, diskpath string, metadata map[string]*string) error {
+	file, err := os.Open(diskpath) // #nosec G304 - provider takes care of filepath clean call
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	body := file
+	if p.compressed() || p.aead != nil {
+		// The upload requires a seekable body, so the compressed and/or
+		// encrypted content is written to a temporary file first
+		encoded, err := os.CreateTemp("", "go-cache-prog-upload-*")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = encoded.Close()
+			_ = os.Remove(encoded.Name())
+		}()
+
+		if err := p.encode(encoded, file); err != nil {
+			return err
+		}
+
+		if _, err := encoded.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		body = encoded
+		if p.compressed() {
+			metadata[compressionKey] = ptr(p.options.Compression)
+		}
+
+		if p.aead != nil {
+			metadata[encryptionKey] = ptr(encryptionScheme)
+		}
+	}
+
+	contentLength, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if p.multipart(contentLength) {
+		return p.uploadParts(ctx, key, body, contentLength, metadata)
+	}
+
+	_, err = p.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: &p.bucket,
+		Key:    &key,
+
+		Metadata: metadata,
+
+		Body:          body,
+		ContentLength: &contentLength,
+	}, withoutSDKRetries)
+
+	return err
+}
+
+func (p *provider) compressed() bool {
+	return p.options.Compression != "" && p.options.Compression != CompressionNone
+}
+
+// encode writes the content as it is stored remotely, the content is first
+// compressed and then encrypted, if configured
+func (p *provider) encode(w io.Writer, r io.Reader) error {
+	if p.compressed() {
+		pr, pw := io.Pipe()
+		defer func() { _ = pr.Close() }()
+
+		go func(r io.Reader) {
+			pw.CloseWithError(compress(p.options.CompressionLevel, pw, r))
+		}(r)
+
+		r = pr
+	}
+
+	if p.aead != nil {
+		return encrypt(p.aead, w, r)
+	}
+
+	_, err := io.Copy(w, r)
+	return err
+}
+
+func (p *provider) Close(ctx context.Context) error {
+	p.stopPrefetch()
+	p.prefetching.Wait()
+
+	if err := p.writeManifest(ctx); err != nil {
+		p.log.Printf("failed to write manifest %s: %v", p.manifestName(), err)
+	}
+
+	// Wait for pending uploads, including the replayed ones, before closing
+	// the local provider, which might remove objects from the cache
+	// directory when trimming it
+	p.replaying.Wait()
+	err := p.uploads.Close(ctx)
+	p.breaker.close()
+	if summary := p.uploads.Summary(); summary != "" {
+		p.log.Printf("uploads to bucket %s: %s", p.bucket, summary)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if repaired := p.repaired.Load(); repaired > 0 {
+		p.log.Printf("repaired %d invalid objects in bucket %s", repaired, p.bucket)
+	}
+
+	if rejected := p.rejected.Load(); rejected > 0 {
+		p.log.Printf("rejected %d action objects without valid signature in bucket %s", rejected, p.bucket)
+	}
+
+	if p.hits.Load()+p.misses.Load()+p.transientErrors.Load()+p.permanentErrors.Load() > 0 {
+		p.log.Printf("gets from bucket %s: %d hits, %d misses, %d transient errors, %d permanent errors",
+			p.bucket, p.hits.Load(), p.misses.Load(), p.transientErrors.Load(), p.permanentErrors.Load())
+	}
 
 	if err := p.localProvider.Close(); err != nil {
 		return err
 	}
 
-	p.uploadGroup.Wait()
 	p.client.Config.HTTPClient.CloseIdleConnections()
 	return nil
 }
 
+var errSizeMismatch = errors.New("size mismatch")
+
+// sizeCheckReader fails when the content of the reader does not have the
+// expected size
+type sizeCheckReader struct {
+	r    io.Reader
+	size int64
+	read int64
+}
+
+func (s *sizeCheckReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.read += int64(n)
+
+	switch {
+	case s.read > s.size:
+		return n, fmt.Errorf("%w: expected %d bytes, but got more", errSizeMismatch, s.size)
+
+	case errors.Is(err, io.EOF) && s.read != s.size:
+		return n, fmt.Errorf("%w: expected %d bytes, but got %d", errSizeMismatch, s.size, s.read)
+	}
+
+	return n, err
+}
+
 func notFound() (string, string, error) {
 	return "", "", nil
 }
@@ -0,0 +1,431 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/ibm-cos-sdk-go/aws"
+	"github.com/IBM/ibm-cos-sdk-go/aws/credentials"
+	"github.com/IBM/ibm-cos-sdk-go/aws/session"
+	"github.com/IBM/ibm-cos-sdk-go/service/s3"
+)
+
+func newTestClient(t *testing.T, endpoint string, maxRetries int) *s3.S3 {
+	t.Helper()
+
+	session, err := session.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s3.New(session, aws.NewConfig().
+		WithEndpoint(endpoint).
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")).
+		WithLowerCaseHeaderMaps(true).
+		WithS3ForcePathStyle(true).
+		WithMaxRetries(maxRetries))
+}
+
+type fakeObject struct {
+	body     []byte
+	metadata map[string]string
+	modified time.Time
+}
+
+// fakeS3 is an in-memory S3 server supporting the requests of the provider
+// using path-style addressing, metadata keys are stored in lower case
+type fakeS3 struct {
+	mutex    sync.Mutex
+	objects  map[string]fakeObject
+	requests []string
+	failures []fakeFailure
+
+	uploads map[string]*fakeUpload
+
+	// truncations is the number of next downloads that are cut off
+	truncations int
+}
+
+// fakeUpload is a multipart upload that is not completed yet
+type fakeUpload struct {
+	metadata map[string]string
+	parts    map[int][]byte
+}
+
+// fakeFailure fails the next requests with the method
+type fakeFailure struct {
+	method string
+	count  int
+	status int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *s3.S3) {
+	t.Helper()
+
+	f := &fakeS3{objects: map[string]fakeObject{}, uploads: map[string]*fakeUpload{}}
+
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	return f, newTestClient(t, server.URL, 0)
+}
+
+func newTestProvider(t *testing.T, client *s3.S3, options Options) *provider {
+	t.Helper()
+
+	if options.CacheDir == "" {
+		options.CacheDir = t.TempDir()
+	}
+
+	provider, err := NewProviderWithClient(client, "bucket", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return provider
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	if status, failed := f.failed(r.Method, key); failed {
+		fakeError(w, r, status, http.StatusText(status))
+		return
+	}
+
+	query := r.URL.Query()
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, query.Get("prefix"))
+
+	case query.Has("uploads"), query.Has("uploadId"):
+		f.multipart(w, r, key)
+
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		obj, found := f.object(key)
+		if !found {
+			fakeError(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+
+		for name, value := range obj.metadata {
+			w.Header().Set("X-Amz-Meta-"+name, value)
+		}
+
+		etag := fmt.Sprintf("%q", outputId(string(obj.body)))
+		if match := r.Header.Get("If-Match"); match != "" && match != etag {
+			fakeError(w, r, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+
+		body, status := obj.body, http.StatusOK
+		if from, to, found := fakeRange(r.Header.Get("Range")); found {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", from, to, len(body)))
+			body, status = body[from:to+1], http.StatusPartialContent
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+
+		if r.Method == http.MethodGet {
+			if f.truncated() {
+				body = body[:len(body)/2]
+			}
+
+			_, _ = w.Write(body)
+		}
+
+	case r.Method == http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			fakeError(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			_, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+
+			obj, found := f.object(sourceKey)
+			if !found {
+				fakeError(w, r, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+
+			body = obj.body
+			defer func() { _, _ = io.WriteString(w, "<CopyObjectResult></CopyObjectResult>") }()
+		}
+
+		f.put(key, body, fakeMetadata(r.Header))
+
+	case r.Method == http.MethodDelete:
+		f.mutex.Lock()
+		delete(f.objects, key)
+		f.mutex.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		fakeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// multipart handles the requests of multipart uploads
+func (f *fakeS3) multipart(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+	uploadId := query.Get("uploadId")
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	upload, found := f.uploads[uploadId]
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadId = fmt.Sprintf("upload-%d", len(f.requests))
+		f.uploads[uploadId] = &fakeUpload{metadata: fakeMetadata(r.Header), parts: map[int][]byte{}}
+
+		_, _ = fmt.Fprintf(w, "<InitiateMultipartUploadResult><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", key, uploadId)
+
+	case !found:
+		fakeError(w, r, http.StatusNotFound, "NoSuchUpload")
+
+	case r.Method == http.MethodPut:
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		body, err := io.ReadAll(r.Body)
+		if err != nil || number < 1 {
+			fakeError(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+
+		upload.parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, number))
+
+	case r.Method == http.MethodPost:
+		var body []byte
+		for number := 1; number <= len(upload.parts); number++ {
+			body = append(body, upload.parts[number]...)
+		}
+
+		f.objects[key] = fakeObject{body: body, metadata: upload.metadata, modified: time.Now()}
+		delete(f.uploads, uploadId)
+
+		_, _ = io.WriteString(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+
+	case r.Method == http.MethodDelete:
+		delete(f.uploads, uploadId)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func fakeMetadata(header http.Header) map[string]string {
+	metadata := map[string]string{}
+	for name := range header {
+		if suffix, found := strings.CutPrefix(strings.ToLower(name), "x-amz-meta-"); found {
+			metadata[suffix] = header.Get(name)
+		}
+	}
+
+	return metadata
+}
+
+func fakeRange(header string) (int, int, bool) {
+	var from, to int
+	if _, err := fmt.Sscanf(header, "bytes=%d-%d", &from, &to); err != nil {
+		return 0, 0, false
+	}
+
+	return from, to, true
+}
+
+// truncate cuts off the next count downloads in the middle of the body
+func (f *fakeS3) truncate(count int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.truncations = count
+}
+
+func (f *fakeS3) truncated() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.truncations == 0 {
+		return false
+	}
+
+	f.truncations--
+	return true
+}
+
+func fakeError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = io.WriteString(w, "<Error><Code>"+code+"</Code><Message>"+code+"</Message></Error>")
+	}
+}
+
+// fail fails the next count requests with the method using the status
+func (f *fakeS3) fail(method string, count int, status int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.failures = append(f.failures, fakeFailure{method: method, count: count, status: status})
+}
+
+// failed records the request, and checks whether it has to fail
+func (f *fakeS3) failed(method string, key string) (int, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.requests = append(f.requests, method+" "+key)
+
+	for i := range f.failures {
+		if failure := &f.failures[i]; failure.method == method && failure.count > 0 {
+			failure.count--
+			return failure.status, true
+		}
+	}
+
+	return 0, false
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	keys := slices.Sorted(maps.Keys(f.objects))
+
+	_, _ = io.WriteString(w, "<ListBucketResult><IsTruncated>false</IsTruncated>")
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			obj := f.objects[key]
+			_, _ = fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>%s</LastModified></Contents>",
+				key, len(obj.body), obj.modified.UTC().Format(time.RFC3339))
+		}
+	}
+	_, _ = io.WriteString(w, "</ListBucketResult>")
+}
+
+func (f *fakeS3) put(key string, body []byte, metadata map[string]string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.objects[key] = fakeObject{body: body, metadata: metadata, modified: time.Now()}
+}
+
+func (f *fakeS3) object(key string) (fakeObject, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	obj, found := f.objects[key]
+	return obj, found
+}
+
+// count returns the number of requests with the method for keys with the
+// prefix
+func (f *fakeS3) count(method string, prefix string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var count int
+	for _, request := range f.requests {
+		if strings.HasPrefix(request, method+" "+prefix) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// content returns the object with the content of the action, which is
+// either the action object itself or the object referenced by the record
+func (f *fakeS3) content(t *testing.T, actionId string) fakeObject {
+	t.Helper()
+
+	obj, found := f.object("action/" + actionId)
+	if key := obj.metadata[objectKeyKey]; found && key != "" {
+		obj, found = f.object(key)
+	}
+
+	if !found {
+		t.Fatalf("no content of action %s", actionId)
+	}
+
+	return obj
+}
+
+func outputId(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// putEntry puts the entry using the provider, and closes the provider to
+// wait for the upload
+func putEntry(t *testing.T, p *provider, actionId string, content string) string {
+	t.Helper()
+
+	objectId := outputId(content)
+	if _, err := p.Put(context.Background(), actionId, objectId, strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return objectId
+}
+
+func TestPutAfterClose(t *testing.T) {
+	fake, client := newFakeS3(t)
+
+	p := newTestProvider(t, client, Options{MinUploadSize: 1})
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	content := "build output"
+	diskpath, err := p.Put(context.Background(), "0a", outputId(content), strings.NewReader(content))
+	if err != nil || diskpath == "" {
+		t.Fatalf("got %q, %v, want object in the local cache directory", diskpath, err)
+	}
+
+	if uploads := fake.count("PUT", ""); uploads != 0 {
+		t.Errorf("got %d uploads after closing, want none", uploads)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const encryptionKey = "encryption"
+
+// encryptionScheme is stored as metadata of encrypted action objects
+const encryptionScheme = "aes-256-gcm-chunked-v1"
+
+// Encrypted content is split into chunks that are sealed separately, so that
+// content can be encrypted and decrypted while streaming. The nonce of each
+// chunk consists of a random prefix stored in the header, and the chunk
+// number. The last chunk is marked using the additional data, which detects
+// truncated content.
+const (
+	chunkSize       = 64 * 1024
+	noncePrefixSize = 8
+	headerSize      = 1 + noncePrefixSize
+	headerVersion   = 1
+)
+
+var errDecryptionFailed = errors.New("decryption failed")
+
+// parseEncryptionKey parses a hex or base64 encoded 256 bit key
+func parseEncryptionKey(key string) (cipher.AEAD, error) {
+	key = strings.TrimSpace(key)
+
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		if raw, err = base64.StdEncoding.DecodeString(key); err != nil {
+			return nil, fmt.Errorf("encryption key has to be hex or base64 encoded")
+		}
+	}
+
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("encryption key has to be 256 bits long, but is %d bits long", len(raw)*8)
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, chunk uint32) []byte {
+	nonce := make([]byte, noncePrefixSize+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], chunk)
+	return nonce
+}
+
+func chunkAdditionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+
+	return []byte{0}
+}
+
+// encrypt writes the encrypted content of the reader to the writer
+func encrypt(aead cipher.AEAD, w io.Writer, r io.Reader) error {
+	header := make([]byte, headerSize)
+	header[0] = headerVersion
+	if _, err := rand.Read(header[1:]); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	// Reading one byte ahead tells whether the current chunk is the last one
+	buf := make([]byte, chunkSize+1)
+	n, err := io.ReadFull(r, buf)
+	for chunk := uint32(0); ; chunk++ {
+		last := n <= chunkSize
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		case err != nil:
+			return err
+		}
+
+		plain := buf[:min(n, chunkSize)]
+		if _, err := w.Write(aead.Seal(nil, chunkNonce(header[1:], chunk), plain, chunkAdditionalData(last))); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+
+		// Keep the byte that was read ahead as start of the next chunk
+		buf[0] = buf[chunkSize]
+		n, err = io.ReadFull(r, buf[1:])
+		n++
+	}
+}
+
+// decryptingReader decrypts content written by encrypt
+type decryptingReader struct {
+	aead   cipher.AEAD
+	r      io.Reader
+	prefix []byte
+	chunk  uint32
+	plain  []byte
+	buf    []byte
+	done   bool
+}
+
+func newDecryptingReader(aead cipher.AEAD, r io.Reader) *decryptingReader {
+	return &decryptingReader{
+		aead: aead,
+		r:    r,
+		buf:  make([]byte, chunkSize+aead.Overhead()+1),
+	}
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptingReader) next() error {
+	if d.prefix == nil {
+		header := make([]byte, headerSize)
+		if _, err := io.ReadFull(d.r, header); err != nil {
+			return fmt.Errorf("%w: %v", errDecryptionFailed, err)
+		}
+
+		if header[0] != headerVersion {
+			return fmt.Errorf("%w: unsupported version %d", errDecryptionFailed, header[0])
+		}
+
+		d.prefix = header[1:]
+
+		// Read the first chunk plus one byte ahead, which tells whether the
+		// chunk is the last one
+		n, err := io.ReadFull(d.r, d.buf)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: %v", errDecryptionFailed, err)
+		}
+
+		d.buf = d.buf[:n]
+	}
+
+	sealedSize := chunkSize + d.aead.Overhead()
+	last := len(d.buf) <= sealedSize
+
+	sealed := d.buf[:min(len(d.buf), sealedSize)]
+	plain, err := d.aead.Open(nil, chunkNonce(d.prefix, d.chunk), sealed, chunkAdditionalData(last))
+	if err != nil {
+		return errDecryptionFailed
+	}
+
+	d.plain, d.chunk = plain, d.chunk+1
+
+	if last {
+		d.done = true
+		return nil
+	}
+
+	// Keep the byte that was read ahead as start of the next chunk
+	d.buf = d.buf[:cap(d.buf)]
+	d.buf[0] = d.buf[sealedSize]
+	n, err := io.ReadFull(d.r, d.buf[1:])
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %v", errDecryptionFailed, err)
+	}
+
+	d.buf = d.buf[:n+1]
+	return nil
+}
@@ -0,0 +1,211 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const testEncryptionKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func newTestAEAD(t *testing.T, key string) cipher.AEAD {
+	t.Helper()
+
+	aead, err := parseEncryptionKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return aead
+}
+
+func encrypted(t *testing.T, aead cipher.AEAD, plain []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := encrypt(aead, &buf, iotest.HalfReader(bytes.NewReader(plain))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func decrypt(aead cipher.AEAD, data []byte) ([]byte, error) {
+	return io.ReadAll(iotest.OneByteReader(newDecryptingReader(aead, iotest.HalfReader(bytes.NewReader(data)))))
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	aead := newTestAEAD(t, testEncryptionKey)
+
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 7} {
+		plain := bytes.Repeat([]byte{0x2a}, size)
+		data := encrypted(t, aead, plain)
+
+		chunks := max(1, (size+chunkSize-1)/chunkSize)
+		if want := headerSize + size + chunks*aead.Overhead(); len(data) != want {
+			t.Errorf("size %d: got %d encrypted bytes, want %d", size, len(data), want)
+		}
+
+		if size >= 16 && bytes.Contains(data, plain) {
+			t.Errorf("size %d: encrypted content contains the plain content", size)
+		}
+
+		got, err := decrypt(aead, data)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: decrypted content does not match", size)
+		}
+	}
+}
+
+func TestDecryptInvalidContent(t *testing.T) {
+	aead := newTestAEAD(t, testEncryptionKey)
+	sealedSize := chunkSize + aead.Overhead()
+
+	plain := bytes.Repeat([]byte("0123456789"), 3*chunkSize/10)
+	data := encrypted(t, aead, plain)
+
+	tests := []struct {
+		name   string
+		aead   cipher.AEAD
+		modify func(data []byte) []byte
+	}{
+		{
+			name:   "truncated header",
+			modify: func(data []byte) []byte { return data[:headerSize-1] },
+		},
+		{
+			name:   "truncated after a chunk",
+			modify: func(data []byte) []byte { return data[:headerSize+sealedSize] },
+		},
+		{
+			name:   "truncated within a chunk",
+			modify: func(data []byte) []byte { return data[:len(data)-1] },
+		},
+		{
+			name: "reordered chunks",
+			modify: func(data []byte) []byte {
+				first := data[headerSize : headerSize+sealedSize]
+				second := data[headerSize+sealedSize : headerSize+2*sealedSize]
+
+				var reordered []byte
+				reordered = append(reordered, data[:headerSize]...)
+				reordered = append(reordered, second...)
+				reordered = append(reordered, first...)
+				return append(reordered, data[headerSize+2*sealedSize:]...)
+			},
+		},
+		{
+			name:   "unsupported version",
+			modify: func(data []byte) []byte { data[0] = headerVersion + 1; return data },
+		},
+		{
+			name:   "tampered nonce prefix",
+			modify: func(data []byte) []byte { data[1] ^= 1; return data },
+		},
+		{
+			name:   "tampered content",
+			modify: func(data []byte) []byte { data[headerSize+sealedSize+1] ^= 1; return data },
+		},
+		{
+			name:   "wrong key",
+			aead:   newTestAEAD(t, strings.Repeat("ff", 32)),
+			modify: func(data []byte) []byte { return data },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aead := aead
+			if tt.aead != nil {
+				aead = tt.aead
+			}
+
+			if _, err := decrypt(aead, tt.modify(bytes.Clone(data))); !errors.Is(err, errDecryptionFailed) {
+				t.Errorf("got %v, want %v", err, errDecryptionFailed)
+			}
+		})
+	}
+}
+
+func TestParseEncryptionKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		valid bool
+	}{
+		{name: "hex", key: testEncryptionKey, valid: true},
+		{name: "base64", key: "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=", valid: true},
+		{name: "trailing newline", key: testEncryptionKey + "\n", valid: true},
+		{name: "too short", key: testEncryptionKey[:32]},
+		{name: "invalid encoding", key: "not a key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseEncryptionKey(tt.key); tt.valid != (err == nil) {
+				t.Errorf("got %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestEncryptedEntries(t *testing.T) {
+	fake, client := newFakeS3(t)
+
+	content := strings.Repeat("secret build output ", 10000)
+	objectId := putEntry(t, newTestProvider(t, client, Options{MinUploadSize: 1, EncryptionKey: testEncryptionKey}), "0a", content)
+
+	obj := fake.content(t, "0a")
+	if obj.metadata[encryptionKey] != encryptionScheme || bytes.Contains(obj.body, []byte("secret build output")) {
+		t.Fatal("expected only encrypted content to be uploaded")
+	}
+
+	got, diskpath, err := newTestProvider(t, client, Options{EncryptionKey: testEncryptionKey}).Get(context.Background(), "0a")
+	if err != nil || got != objectId {
+		t.Fatalf("got %q, %v, want hit of encrypted entry", got, err)
+	}
+
+	// The local cache directory keeps the plain content for the toolchain
+	if data, err := os.ReadFile(diskpath); err != nil || string(data) != content {
+		t.Errorf("expected plain content in the local cache directory, got %v", err)
+	}
+
+	for name, options := range map[string]Options{
+		"without key": {},
+		"wrong key":   {EncryptionKey: strings.Repeat("ff", 32)},
+	} {
+		if got, _, err := newTestProvider(t, client, options).Get(context.Background(), "0a"); err != nil || got != "" {
+			t.Errorf("%s: got %q, %v, want miss", name, got, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/IBM/ibm-cos-sdk-go/aws/awserr"
+	"github.com/IBM/ibm-cos-sdk-go/aws/client"
+	"github.com/IBM/ibm-cos-sdk-go/aws/request"
+	"github.com/IBM/ibm-cos-sdk-go/service/s3"
+	"github.com/homeport/go-cache-prog/pkg/provider/internal/transfer"
+)
+
+var errRemoteDisabled = fmt.Errorf("remote storage is unavailable: %w", transfer.ErrSkipped)
+
+// isNotFound checks whether the error is caused by a missing object
+func isNotFound(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+
+	switch aerr.Code() {
+	case s3.ErrCodeNoSuchKey, "NotFound":
+		return true
+
+	default:
+		return false
+	}
+}
+
+// isTransient checks whether the error is likely to go away when retrying,
+// e.g. throttling, server errors, timeouts, and network errors
+func isTransient(err error) bool {
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) {
+		return requestFailure.StatusCode() >= 500 || requestFailure.StatusCode() == 429
+	}
+
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case request.ErrCodeRequestError, request.ErrCodeResponseTimeout, request.ErrCodeRead:
+			return true
+		}
+	}
+
+	// Errors while reading the body of a response are not wrapped
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, transfer.ErrTimeout)
+}
+
+// isCancelled checks whether the error is caused by a cancelled context,
+// which is not a failure of the remote storage
+func isCancelled(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == request.CanceledErrorCode
+}
+
+// classify wraps the error of the remote storage with its class, so that it
+// can be told apart in the log
+func classify(err error) error {
+	switch {
+	case isTransient(err):
+		return fmt.Errorf("transient error of remote storage: %w", err)
+
+	default:
+		return fmt.Errorf("permanent error of remote storage: %w", err)
+	}
+}
+
+// withoutSDKRetries disables the retries of the SDK for a request that is
+// retried by the provider, so that both retries do not multiply
+func withoutSDKRetries(r *request.Request) {
+	r.Retryer = client.NoOpRetryer{}
+}
+
+// retry runs the function and retries it with exponential backoff as long
+// as it fails with a transient error, up to the given number of retries
+func retry(ctx context.Context, retries int, backoff time.Duration, f func() error) error {
+	return transfer.Retry(ctx, retries, backoff, isTransient, f)
+}
@@ -0,0 +1,136 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/IBM/ibm-cos-sdk-go/aws/awserr"
+	"github.com/IBM/ibm-cos-sdk-go/aws/request"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{name: "server error", err: awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "", nil), 503, ""), transient: true},
+		{name: "throttling", err: awserr.NewRequestFailure(awserr.New("SlowDown", "", nil), 429, ""), transient: true},
+		{name: "access denied", err: awserr.NewRequestFailure(awserr.New("AccessDenied", "", nil), 403, "")},
+		{name: "missing object", err: awserr.NewRequestFailure(awserr.New("NoSuchKey", "", nil), 404, "")},
+		{name: "request error", err: awserr.New(request.ErrCodeRequestError, "", nil), transient: true},
+		{name: "network error", err: fmt.Errorf("read: %w", &net.OpError{Op: "read", Err: errors.New("connection reset")}), transient: true},
+		{name: "truncated body", err: io.ErrUnexpectedEOF, transient: true},
+		{name: "other error", err: errors.New("other")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if transient := isTransient(tt.err); transient != tt.transient {
+				t.Errorf("got transient %v, want %v", transient, tt.transient)
+			}
+		})
+	}
+}
+
+func TestGetRetries(t *testing.T) {
+	content := strings.Repeat("build output ", 1000)
+
+	tests := []struct {
+		name     string
+		failures int
+		status   int
+		gets     int
+		hit      bool
+	}{
+		{name: "transient errors within retries", failures: 2, status: http.StatusServiceUnavailable, gets: 3, hit: true},
+		{name: "transient errors exceeding retries", failures: 3, status: http.StatusServiceUnavailable, gets: 3},
+		{name: "permanent error", failures: 1, status: http.StatusForbidden, gets: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+			putEntry(t, newTestProvider(t, client, Options{MinUploadSize: 1, Layout: LayoutAction}), "0a", content)
+
+			fake.fail(http.MethodGet, tt.failures, tt.status)
+
+			var logs bytes.Buffer
+			p := newTestProvider(t, client, Options{DownloadRetries: 2})
+			p.WithLogOutput(&logs)
+
+			got, _, err := p.Get(context.Background(), "0a")
+			switch {
+			case tt.hit && (err != nil || got != outputId(content)):
+				t.Errorf("got %q, %v, want hit", got, err)
+
+			case !tt.hit && err == nil:
+				t.Error("expected the failing remote storage to be reported")
+			}
+
+			if gets := fake.count(http.MethodGet, "action/0a"); gets != tt.gets {
+				t.Errorf("got %d gets, want %d", gets, tt.gets)
+			}
+
+			if err := p.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(logs.String(), "gets from bucket bucket: ") {
+				t.Errorf("expected statistics of gets to be logged, got %q", logs.String())
+			}
+		})
+	}
+}
+
+func TestGetRetriesOnlyInProvider(t *testing.T) {
+	var gets atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+		}
+
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	p := newTestProvider(t, newTestClient(t, server.URL, 3), Options{DownloadRetries: 2})
+	defer func() { _ = p.Close(context.Background()) }()
+
+	if _, _, err := p.Get(context.Background(), "0a"); err == nil {
+		t.Fatal("expected the unavailable remote storage to be reported")
+	}
+
+	if gets.Load() != 3 {
+		t.Errorf("got %d requests, want 3 (one attempt and two retries of the provider)", gets.Load())
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// journal records pending uploads in the local cache directory, so that
+// uploads that did not finish, e.g. because the process was killed, can be
+// replayed by the next run. Each pending upload is a file named after the
+// action id, which contains the object id.
+type journal struct {
+	dir string
+}
+
+func newJournal(cacheDir string) (*journal, error) {
+	dir := filepath.Join(cacheDir, "journal")
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	return &journal{dir: dir}, nil
+}
+
+func (j *journal) add(actionId string, objectId string) error {
+	if _, err := hex.DecodeString(actionId); err != nil {
+		return fmt.Errorf("invalid action id %q", actionId)
+	}
+
+	return os.WriteFile(filepath.Join(j.dir, actionId), []byte(objectId), 0600)
+}
+
+// remove removes the pending upload, unless it was replaced by an upload of
+// the action with another object id in the meantime
+func (j *journal) remove(actionId string, objectId string) {
+	path := filepath.Join(j.dir, actionId)
+
+	data, err := os.ReadFile(path) // #nosec G304 - action id is validated to be hex encoded
+	if err != nil || strings.TrimSpace(string(data)) != objectId {
+		return
+	}
+
+	_ = os.Remove(path)
+}
+
+// entries returns the pending uploads by action id, invalid entries, e.g.
+// partially written ones, are removed
+func (j *journal) entries() (map[string]string, error) {
+	dirEntries, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string]string{}
+	for _, dirEntry := range dirEntries {
+		path := filepath.Join(j.dir, dirEntry.Name())
+
+		if _, err := hex.DecodeString(dirEntry.Name()); err != nil || dirEntry.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(path) // #nosec G304 - action id is validated to be hex encoded
+		if err != nil {
+			continue
+		}
+
+		objectId := strings.TrimSpace(string(data))
+		if _, err := hex.DecodeString(objectId); err != nil || objectId == "" {
+			_ = os.Remove(path)
+			continue
+		}
+
+		entries[dirEntry.Name()] = objectId
+	}
+
+	return entries, nil
+}
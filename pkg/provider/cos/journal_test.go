@@ -0,0 +1,188 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	j, err := newJournal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for actionId, objectId := range map[string]string{"0a": "1a", "0b": "1b", "0c": "1c"} {
+		if err := j.add(actionId, objectId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := j.add("../action", "1a"); err == nil {
+		t.Error("expected invalid action id to fail")
+	}
+
+	// Removing an entry replaced by another upload of the action keeps it
+	j.remove("0a", "1a")
+	j.remove("0b", "2b")
+
+	// Partially written entries are removed
+	if err := os.WriteFile(filepath.Join(j.dir, "0d"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := j.entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 || entries["0b"] != "1b" || entries["0c"] != "1c" {
+		t.Errorf("got entries %v", entries)
+	}
+
+	if _, err := os.Stat(filepath.Join(j.dir, "0d")); !os.IsNotExist(err) {
+		t.Error("expected invalid entry to be removed")
+	}
+}
+
+func TestReplayJournal(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		replayed int
+	}{
+		{name: "transient error", status: http.StatusServiceUnavailable, replayed: 1},
+		{name: "permanent error", status: http.StatusForbidden, replayed: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+			fake.fail(http.MethodPut, 1, tt.status)
+
+			cacheDir := t.TempDir()
+			content := strings.Repeat("build output ", 1000)
+
+			options := Options{CacheDir: cacheDir, MinUploadSize: 1, Layout: LayoutAction, UploadRetries: -1}
+			putEntry(t, newTestProvider(t, client, options), "0a", content)
+
+			if _, found := fake.object("action/0a"); found {
+				t.Fatal("expected first upload to fail")
+			}
+
+			p := newTestProvider(t, client, options)
+			if replayed := p.ReplayJournal(); replayed != tt.replayed {
+				t.Errorf("got %d replayed uploads, want %d", replayed, tt.replayed)
+			}
+
+			if replayed := p.ReplayJournal(); replayed != 0 {
+				t.Errorf("got %d replayed uploads on second call, want 0", replayed)
+			}
+
+			if err := p.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, found := fake.object("action/0a"); found != (tt.replayed > 0) {
+				t.Errorf("got uploaded %v, want %v", found, tt.replayed > 0)
+			}
+
+			if entries, _ := p.journal.entries(); len(entries) != 0 {
+				t.Errorf("expected journal to be empty, got %v", entries)
+			}
+		})
+	}
+}
+
+func TestReplayJournalInBackground(t *testing.T) {
+	fake, client := newFakeS3(t)
+	fake.fail(http.MethodPut, 1, http.StatusServiceUnavailable)
+
+	cacheDir := t.TempDir()
+	content := strings.Repeat("build output ", 1000)
+
+	options := Options{CacheDir: cacheDir, MinUploadSize: 1, Layout: LayoutAction, UploadRetries: -1}
+	putEntry(t, newTestProvider(t, client, options), "0a", content)
+
+	// The first get of the next run replays the pending upload, uploads of
+	// the current run are not replayed again
+	p := newTestProvider(t, client, options)
+	if got, _, err := p.Get(context.Background(), "0b"); err != nil || got != "" {
+		t.Fatalf("got %q, %v, want miss", got, err)
+	}
+
+	if _, err := p.Put(context.Background(), "0c", outputId(content), strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found := fake.object("action/0a"); !found {
+		t.Error("expected pending upload to be replayed")
+	}
+
+	if uploads := fake.count(http.MethodPut, "action/0c"); uploads != 1 {
+		t.Errorf("got %d uploads of action/0c, want 1", uploads)
+	}
+
+	if replayed := p.ReplayJournal(); replayed != 0 {
+		t.Errorf("got %d replayed uploads after the first get, want 0", replayed)
+	}
+}
+
+func TestReplayJournalSkipsMissingEntries(t *testing.T) {
+	fake, client := newFakeS3(t)
+
+	cacheDir := t.TempDir()
+	j, err := newJournal(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := j.add("0a", outputId("content")); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newTestProvider(t, client, Options{CacheDir: cacheDir})
+
+	if replayed := p.ReplayJournal(); replayed != 0 {
+		t.Errorf("got %d replayed uploads, want 0", replayed)
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries, _ := p.journal.entries(); len(entries) != 0 {
+		t.Errorf("expected entry missing in the cache directory to be removed, got %v", entries)
+	}
+
+	if uploads := fake.count(http.MethodPut, ""); uploads != 0 {
+		t.Errorf("got %d uploads, want none", uploads)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestObjectLayout(t *testing.T) {
+	fake, client := newFakeS3(t)
+
+	content := strings.Repeat("shared build output ", 1000)
+
+	// The second upload finds the object uploaded by the first one
+	for _, actionId := range []string{"0a", "0b"} {
+		putEntry(t, newTestProvider(t, client, Options{MinUploadSize: 1, Layout: LayoutObject}), actionId, content)
+	}
+
+	objectKey := "object/" + outputId(content)
+	for _, actionId := range []string{"0a", "0b"} {
+		record, _ := fake.object("action/" + actionId)
+		if len(record.body) != 0 || record.metadata[objectKeyKey] != objectKey {
+			t.Errorf("expected action record of %s to refer to %s", actionId, objectKey)
+		}
+
+		got, diskpath, err := newTestProvider(t, client, Options{}).Get(context.Background(), actionId)
+		if err != nil || got != outputId(content) {
+			t.Fatalf("%s: got %q, %v, want hit", actionId, got, err)
+		}
+
+		if data, err := os.ReadFile(diskpath); err != nil || string(data) != content {
+			t.Errorf("%s: unexpected content, %v", actionId, err)
+		}
+	}
+
+	if uploads := fake.count("PUT", objectKey); uploads != 1 {
+		t.Errorf("got %d uploads of %s, want 1", uploads, objectKey)
+	}
+}
+
+func TestObjectLayoutEncoding(t *testing.T) {
+	content := strings.Repeat("shared build output ", 1000)
+	objectKey := "object/" + outputId(content)
+
+	tests := []struct {
+		name        string
+		first       Options
+		second      Options
+		wantUploads int
+	}{
+		{
+			name:        "same encoding",
+			first:       Options{Compression: CompressionGzip, EncryptionKey: testEncryptionKey},
+			second:      Options{Compression: CompressionGzip, EncryptionKey: testEncryptionKey},
+			wantUploads: 1,
+		},
+		{
+			name:        "encryption configured",
+			first:       Options{},
+			second:      Options{EncryptionKey: testEncryptionKey},
+			wantUploads: 2,
+		},
+		{
+			name:        "encryption removed",
+			first:       Options{EncryptionKey: testEncryptionKey},
+			second:      Options{},
+			wantUploads: 2,
+		},
+		{
+			name:        "compression changed",
+			first:       Options{Compression: CompressionGzip},
+			second:      Options{Compression: CompressionNone},
+			wantUploads: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+
+			tt.first.MinUploadSize, tt.first.Layout = 1, LayoutObject
+			tt.second.MinUploadSize, tt.second.Layout = 1, LayoutObject
+
+			putEntry(t, newTestProvider(t, client, tt.first), "0a", content)
+			putEntry(t, newTestProvider(t, client, tt.second), "0b", content)
+
+			if uploads := fake.count("PUT", objectKey); uploads != tt.wantUploads {
+				t.Errorf("got %d uploads of %s, want %d", uploads, objectKey, tt.wantUploads)
+			}
+
+			// The object is readable with the configuration of the last upload
+			if got, _, err := newTestProvider(t, client, tt.second).Get(context.Background(), "0b"); err != nil || got != outputId(content) {
+				t.Errorf("got %q, %v, want hit", got, err)
+			}
+		})
+	}
+}
+
+func TestActionLayout(t *testing.T) {
+	fake, client := newFakeS3(t)
+
+	content := strings.Repeat("build output ", 1000)
+	putEntry(t, newTestProvider(t, client, Options{MinUploadSize: 1, Layout: LayoutAction}), "0a", content)
+
+	if obj, _ := fake.object("action/0a"); string(obj.body) != content {
+		t.Error("expected action object to contain the content")
+	}
+
+	if uploads := fake.count("PUT", "object/"); uploads != 0 {
+		t.Errorf("got %d uploads of content-addressed objects, want none", uploads)
+	}
+
+	if _, err := NewProviderWithClient(client, "bucket", Options{CacheDir: t.TempDir(), Layout: "other"}); err == nil {
+		t.Error("expected unsupported layout to fail")
+	}
+}
+
+func TestInvalidReferencedObjects(t *testing.T) {
+	content := strings.Repeat("build output ", 1000)
+	objectKey := "object/" + outputId(content)
+
+	// recordSize changes the size in the action record, the content of the
+	// referenced object is still valid
+	recordSize := func(size string) func(fake *fakeS3) {
+		return func(fake *fakeS3) {
+			record, _ := fake.object("action/0a")
+			fake.put("action/0a", record.body, map[string]string{objectIdKey: record.metadata[objectIdKey], objectKeyKey: objectKey, sizeKey: size})
+		}
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(fake *fakeS3)
+		wantDeleted []string
+		wantKept    []string
+	}{
+		{
+			name: "missing object",
+			modify: func(fake *fakeS3) {
+				fake.mutex.Lock()
+				delete(fake.objects, objectKey)
+				fake.mutex.Unlock()
+			},
+			wantDeleted: []string{"action/0a", objectKey},
+		},
+		{
+			name: "corrupt object",
+			modify: func(fake *fakeS3) {
+				obj, _ := fake.object(objectKey)
+				fake.put(objectKey, []byte(strings.Repeat("tampered one ", 1000)), obj.metadata)
+			},
+			wantDeleted: []string{"action/0a", objectKey},
+		},
+		{
+			name: "truncated object",
+			modify: func(fake *fakeS3) {
+				obj, _ := fake.object(objectKey)
+				fake.put(objectKey, obj.body[:len(obj.body)/2], obj.metadata)
+			},
+			wantDeleted: []string{"action/0a", objectKey},
+		},
+		{
+			name:        "smaller size in record",
+			modify:      recordSize("1"),
+			wantDeleted: []string{"action/0a"},
+			wantKept:    []string{objectKey},
+		},
+		{
+			name:        "larger size in record",
+			modify:      recordSize(strconv.Itoa(len(content) + 1)),
+			wantDeleted: []string{"action/0a"},
+			wantKept:    []string{objectKey},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeS3(t)
+
+			putEntry(t, newTestProvider(t, client, Options{MinUploadSize: 1, Layout: LayoutObject}), "0a", content)
+			tt.modify(fake)
+
+			if got, _, err := newTestProvider(t, client, Options{}).Get(context.Background(), "0a"); err != nil || got != "" {
+				t.Fatalf("got %q, %v, want miss", got, err)
+			}
+
+			for _, key := range tt.wantDeleted {
+				if _, found := fake.object(key); found {
+					t.Errorf("expected %s to be deleted", key)
+				}
+			}
+
+			for _, key := range tt.wantKept {
+				if _, found := fake.object(key); !found {
+					t.Errorf("expected %s to be kept", key)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	fake, client := newFakeS3(t)
+
+	options := Options{MinUploadSize: 1, Layout: LayoutAction, SigningKey: "secret", Compression: CompressionGzip}
+
+	contents := map[string]string{
+		"0a": strings.Repeat("first build output ", 1000),
+		"0b": strings.Repeat("second build output ", 1000),
+	}
+
+	for actionId, content := range contents {
+		putEntry(t, newTestProvider(t, client, options), actionId, content)
+	}
+
+	p := newTestProvider(t, client, Options{})
+	if migrated, err := p.Migrate(context.Background(), 2); err != nil || migrated != 2 {
+		t.Fatalf("got %d migrated, %v, want 2", migrated, err)
+	}
+
+	if migrated, err := p.Migrate(context.Background(), 2); err != nil || migrated != 0 {
+		t.Fatalf("got %d migrated on second run, %v, want 0", migrated, err)
+	}
+
+	for actionId, content := range contents {
+		record, _ := fake.object("action/" + actionId)
+		if len(record.body) != 0 || record.metadata[signatureKey] == "" {
+			t.Errorf("expected signed action record of %s", actionId)
+		}
+
+		if obj := fake.content(t, actionId); obj.metadata[compressionKey] != CompressionGzip {
+			t.Errorf("expected migrated object of %s to keep the compression", actionId)
+		}
+
+		got, _, err := newTestProvider(t, client, Options{SigningKey: "secret"}).Get(context.Background(), actionId)
+		if err != nil || got != outputId(content) {
+			t.Errorf("%s: got %q, %v, want hit", actionId, got, err)
+		}
+	}
+
+	if _, err := newTestProvider(t, client, Options{ReadOnly: true}).Migrate(context.Background(), 1); err == nil {
+		t.Error("expected migration in read-only mode to fail")
+	}
+}
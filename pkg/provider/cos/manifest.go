@@ -0,0 +1,264 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/ibm-cos-sdk-go/aws"
+	"github.com/IBM/ibm-cos-sdk-go/service/s3"
+	"github.com/homeport/go-cache-prog/pkg/errgroup"
+)
+
+const DefaultPrefetchConcurrency = 16
+
+// manifest records the action ids used in a session, so that the entries
+// can be prefetched by the next session before the toolchain asks for them
+type manifest struct {
+	mu        sync.Mutex
+	actionIds map[string]struct{}
+}
+
+func newManifest() *manifest {
+	return &manifest{actionIds: map[string]struct{}{}}
+}
+
+func (m *manifest) record(actionId string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.actionIds[actionId] = struct{}{}
+}
+
+// marshal returns the recorded action ids sorted, one per line
+func (m *manifest) marshal() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	actionIds := make([]string, 0, len(m.actionIds))
+	for actionId := range m.actionIds {
+		actionIds = append(actionIds, actionId)
+	}
+
+	slices.Sort(actionIds)
+
+	var buf bytes.Buffer
+	for _, actionId := range actionIds {
+		buf.WriteString(actionId)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+func (m *manifest) empty() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.actionIds) == 0
+}
+
+// parseManifest returns the action ids of the manifest, invalid lines are
+// ignored
+func parseManifest(data []byte) []string {
+	var actionIds []string
+	for line := range strings.Lines(string(data)) {
+		actionId := strings.TrimSpace(line)
+		if _, err := hex.DecodeString(actionId); err != nil || actionId == "" {
+			continue
+		}
+
+		actionIds = append(actionIds, actionId)
+	}
+
+	return actionIds
+}
+
+func (p *provider) manifestKey() string {
+	return "manifest/" + p.options.ManifestKey
+}
+
+func (p *provider) manifestName() string {
+	if p.options.ManifestFile != "" {
+		return p.options.ManifestFile
+	}
+
+	return p.manifestKey()
+}
+
+// readManifest reads the manifest of the previous session, a missing
+// manifest has no entries
+func (p *provider) readManifest(ctx context.Context) ([]string, error) {
+	if p.options.ManifestFile != "" {
+		data, err := os.ReadFile(p.options.ManifestFile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return nil, nil
+
+		case err != nil:
+			return nil, err
+		}
+
+		return parseManifest(data), nil
+	}
+
+	res, err := p.getObject(ctx, p.manifestKey())
+	if err != nil || res == nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseManifest(data), nil
+}
+
+// writeManifest replaces the manifest with the action ids of this session,
+// sessions that did not use the cache leave the manifest untouched
+func (p *provider) writeManifest(ctx context.Context) error {
+	if p.manifest == nil || p.manifest.empty() {
+		return nil
+	}
+
+	data := p.manifest.marshal()
+
+	if p.options.ManifestFile != "" {
+		dir := filepath.Dir(p.options.ManifestFile)
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return err
+		}
+
+		// The manifest is replaced atomically, so that a concurrent session
+		// never reads a partially written manifest
+		file, err := os.CreateTemp(dir, filepath.Base(p.options.ManifestFile)+".*")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.Remove(file.Name()) }()
+
+		if _, err := file.Write(data); err != nil {
+			_ = file.Close()
+			return err
+		}
+
+		if err := file.Close(); err != nil {
+			return err
+		}
+
+		return os.Rename(file.Name(), p.options.ManifestFile)
+	}
+
+	if p.options.ReadOnly {
+		return nil
+	}
+
+	_, err := p.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: &p.bucket,
+		Key:    aws.String(p.manifestKey()),
+
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	})
+
+	return err
+}
+
+// Prefetch downloads the entries of the manifest of the previous session in
+// the background, so that the remote round-trips do not add up when the
+// toolchain asks for the entries one by one. It is called with the first
+// get or put, only the first call has an effect.
+func (p *provider) Prefetch() {
+	if p.manifest == nil {
+		return
+	}
+
+	p.prefetchOnce.Do(func() {
+		p.prefetching.Add(1)
+		go func() {
+			defer p.prefetching.Done()
+			p.prefetch(p.prefetchCtx)
+		}()
+	})
+}
+
+func (p *provider) prefetch(ctx context.Context) {
+	start := time.Now()
+
+	actionIds, err := p.readManifest(ctx)
+	switch {
+	case ctx.Err() != nil, err == nil && len(actionIds) == 0:
+		return
+
+	case err != nil:
+		p.log.Printf("failed to read manifest %s: %v", p.manifestName(), err)
+		return
+	}
+
+	var prefetched, missing atomic.Int64
+
+	group, groupCtx := errgroup.New(ctx, p.options.PrefetchConcurrency)
+	for _, actionId := range actionIds {
+		// Entries are no longer prefetched when the remote storage is
+		// unavailable
+		if groupCtx.Err() != nil || !p.breaker.allow() {
+			break
+		}
+
+		if objectId, _, err := p.localProvider.Get(actionId); err == nil && objectId != "" {
+			continue
+		}
+
+		group.Go(func() error {
+			objectId, _, err := p.getRemote(groupCtx, actionId)
+			switch {
+			case groupCtx.Err() != nil:
+
+			case err != nil:
+				p.log.Printf("failed to prefetch action %s: %v", actionId, err)
+
+			case objectId == "":
+				missing.Add(1)
+
+			default:
+				prefetched.Add(1)
+			}
+
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+
+	p.log.Printf("prefetched %d of %d entries of manifest %s in %v, %d missing in bucket %s",
+		prefetched.Load(), len(actionIds), p.manifestName(), time.Since(start).Round(time.Millisecond), missing.Load(), p.bucket)
+}
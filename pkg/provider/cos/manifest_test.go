@@ -0,0 +1,154 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	got := parseManifest([]byte("0a\n\n  0b  \nnot hex\n0c"))
+	if want := []string{"0a", "0b", "0c"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  func(dir string) Options
+		manifest func(fake *fakeS3, dir string) []byte
+	}{
+		{
+			name:    "manifest key",
+			options: func(string) Options { return Options{ManifestKey: "feature/branch"} },
+			manifest: func(fake *fakeS3, _ string) []byte {
+				obj, _ := fake.object("manifest/feature/branch")
+				return obj.body
+			},
+		},
+		{
+			name:    "manifest file",
+			options: func(dir string) Options { return Options{ManifestFile: filepath.Join(dir, "manifests", "main")} },
+			manifest: func(_ *fakeS3, dir string) []byte {
+				data, _ := os.ReadFile(filepath.Join(dir, "manifests", "main"))
+				return data
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake, client := newFakeS3(t)
+
+			dir := t.TempDir()
+			options := tt.options(dir)
+			options.MinUploadSize = 1
+			options.Layout = LayoutAction
+
+			// The first session misses all entries and records them
+			p := newTestProvider(t, client, options)
+
+			var actionIds []string
+			for i := range 20 {
+				actionId := fmt.Sprintf("%02x", i)
+				if objectId, _, err := p.Get(ctx, actionId); err != nil || objectId != "" {
+					t.Fatalf("got %q, %v, want miss", objectId, err)
+				}
+
+				content := fmt.Sprintf("build output %d", i)
+				if _, err := p.Put(ctx, actionId, outputId(content), strings.NewReader(content)); err != nil {
+					t.Fatal(err)
+				}
+
+				actionIds = append(actionIds, actionId)
+			}
+
+			if err := p.Close(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := parseManifest(tt.manifest(fake, dir)); !slices.Equal(got, actionIds) {
+				t.Fatalf("got manifest %v, want %v", got, actionIds)
+			}
+
+			// The next session prefetches the entries with its first get, the
+			// following gets are served from the local cache directory
+			p = newTestProvider(t, client, options)
+			if objectId, _, err := p.Get(ctx, actionIds[0]); err != nil || objectId == "" {
+				t.Fatalf("got %q, %v, want hit", objectId, err)
+			}
+
+			p.prefetching.Wait()
+
+			gets := fake.count("GET", "action/")
+			for _, actionId := range actionIds {
+				if objectId, _, err := p.Get(ctx, actionId); err != nil || objectId == "" {
+					t.Fatalf("%s: got %q, %v, want hit", actionId, objectId, err)
+				}
+			}
+
+			if got := fake.count("GET", "action/"); got != gets {
+				t.Errorf("got %d downloads after prefetching, want none", got-gets)
+			}
+
+			if err := p.Close(ctx); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestManifestUntouched(t *testing.T) {
+	ctx := context.Background()
+	_, client := newFakeS3(t)
+
+	// Read-only sessions and sessions without gets or puts keep the manifest
+	// of the previous session
+	readOnly := newTestProvider(t, client, Options{ManifestKey: "main", ReadOnly: true})
+	if _, _, err := readOnly.Get(ctx, "0a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := readOnly.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	idle := newTestProvider(t, client, Options{ManifestKey: "main"})
+	if err := idle.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if actionIds, err := idle.readManifest(ctx); err != nil || len(actionIds) != 0 {
+		t.Errorf("got manifest %v, %v, want none", actionIds, err)
+	}
+
+	if _, err := NewProviderWithClient(client, "bucket", Options{CacheDir: t.TempDir(), ManifestKey: "main", ManifestFile: "manifest"}); err == nil {
+		t.Error("expected manifest file and manifest key to fail together")
+	}
+}
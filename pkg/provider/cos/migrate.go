@@ -0,0 +1,167 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/IBM/ibm-cos-sdk-go/service/s3"
+	"github.com/homeport/go-cache-prog/pkg/errgroup"
+)
+
+// Migrate converts action objects of the previous layout, which contain the
+// content, into action records that refer to content-addressed objects. The
+// content is copied within the bucket, i.e. it is not downloaded. Migrating
+// is idempotent, action records are skipped.
+func (p *provider) Migrate(ctx context.Context, workers int) (int, error) {
+	if p.options.ReadOnly {
+		return 0, fmt.Errorf("migration is not possible in read-only mode")
+	}
+
+	var migrated, failed atomic.Int64
+
+	group, groupCtx := errgroup.New(ctx, workers)
+	err := p.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: &p.bucket,
+		Prefix: ptr(p.actionKey("")),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			if obj.Key == nil {
+				continue
+			}
+
+			key := *obj.Key
+			group.Go(func() error {
+				ok, err := p.migrate(groupCtx, key)
+				switch {
+				case err != nil:
+					failed.Add(1)
+					p.log.Printf("failed to migrate action object %s: %v", key, err)
+
+				case ok:
+					migrated.Add(1)
+				}
+
+				return nil
+			})
+		}
+
+		return groupCtx.Err() == nil
+	})
+
+	_ = group.Wait()
+
+	switch {
+	case err != nil:
+		return int(migrated.Load()), err
+
+	case failed.Load() > 0:
+		return int(migrated.Load()), fmt.Errorf("failed to migrate %d action objects", failed.Load())
+
+	default:
+		return int(migrated.Load()), ctx.Err()
+	}
+}
+
+func (p *provider) migrate(ctx context.Context, key string) (bool, error) {
+	head, err := p.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: &p.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if lookUp(head.Metadata, objectKeyKey) != "" {
+		return false, nil
+	}
+
+	objectId, found := lookUpObjectId(head.Metadata)
+	if !found {
+		return false, fmt.Errorf("missing or invalid %s metadata", objectIdKey)
+	}
+
+	size, found := lookUpSize(head.Metadata)
+	if !found {
+		return false, fmt.Errorf("missing or invalid %s metadata", sizeKey)
+	}
+
+	objectKey := p.objectKey(objectId)
+	exists, err := p.exists(ctx, objectKey)
+	if err != nil {
+		return false, err
+	}
+
+	if !exists {
+		metadata := map[string]*string{
+			objectIdKey: &objectId,
+			sizeKey:     ptr(strconv.FormatInt(size, 10)),
+		}
+
+		for _, key := range []string{compressionKey, encryptionKey} {
+			if val := lookUp(head.Metadata, key); val != "" {
+				metadata[key] = &val
+			}
+		}
+
+		_, err := p.client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+			Bucket:            &p.bucket,
+			Key:               &objectKey,
+			CopySource:        ptr(p.bucket + "/" + key),
+			MetadataDirective: ptr(s3.MetadataDirectiveReplace),
+			Metadata:          metadata,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	record := map[string]*string{
+		objectIdKey:  &objectId,
+		sizeKey:      ptr(strconv.FormatInt(size, 10)),
+		objectKeyKey: &objectKey,
+	}
+
+	// The signature covers the action id, object id, and size, which are
+	// the same for the action record
+	if signature := lookUp(head.Metadata, signatureKey); signature != "" {
+		record[signatureKey] = &signature
+	}
+
+	_, err = p.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: &p.bucket,
+		Key:    &key,
+
+		Metadata: record,
+
+		Body:          bytes.NewReader(nil),
+		ContentLength: ptr(int64(0)),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,62 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+)
+
+const signatureKey = "signature"
+
+var errInvalidSignature = errors.New("invalid signature")
+
+// sign creates the signature of an action object, it covers the action id,
+// the object id, and the size. The content itself is covered by the object
+// id, which is the hash of the content and verified when downloading.
+func sign(key string, actionId string, objectId string, size int64) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte("go-cache-prog/v1\n" + actionId + "\n" + objectId + "\n" + strconv.FormatInt(size, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks the signature metadata of an action object
+func verifySignature(key string, metadata map[string]*string, actionId string, objectId string, size int64) error {
+	val, found := metadata[signatureKey]
+	if !found || val == nil || *val == "" {
+		return errors.New("missing signature")
+	}
+
+	signature, err := hex.DecodeString(*val)
+	if err != nil {
+		return errInvalidSignature
+	}
+
+	expected, _ := hex.DecodeString(sign(key, actionId, objectId, size))
+	if !hmac.Equal(signature, expected) {
+		return errInvalidSignature
+	}
+
+	return nil
+}
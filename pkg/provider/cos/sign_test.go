@@ -0,0 +1,110 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestVerifySignature(t *testing.T) {
+	signature := sign("key", "0a", "0b", 42)
+
+	tests := []struct {
+		name     string
+		key      string
+		metadata map[string]*string
+		actionId string
+		objectId string
+		size     int64
+		valid    bool
+	}{
+		{name: "valid signature", key: "key", metadata: map[string]*string{signatureKey: &signature}, actionId: "0a", objectId: "0b", size: 42, valid: true},
+		{name: "missing signature", key: "key", metadata: map[string]*string{}, actionId: "0a", objectId: "0b", size: 42},
+		{name: "empty signature", key: "key", metadata: map[string]*string{signatureKey: ptr("")}, actionId: "0a", objectId: "0b", size: 42},
+		{name: "malformed signature", key: "key", metadata: map[string]*string{signatureKey: ptr("not hex")}, actionId: "0a", objectId: "0b", size: 42},
+		{name: "other key", key: "other", metadata: map[string]*string{signatureKey: &signature}, actionId: "0a", objectId: "0b", size: 42},
+		{name: "other action", key: "key", metadata: map[string]*string{signatureKey: &signature}, actionId: "0c", objectId: "0b", size: 42},
+		{name: "other object", key: "key", metadata: map[string]*string{signatureKey: &signature}, actionId: "0a", objectId: "0c", size: 42},
+		{name: "other size", key: "key", metadata: map[string]*string{signatureKey: &signature}, actionId: "0a", objectId: "0b", size: 43},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(tt.key, tt.metadata, tt.actionId, tt.objectId, tt.size)
+			if tt.valid != (err == nil) {
+				t.Errorf("got %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestSignedEntries(t *testing.T) {
+	fake, client := newFakeS3(t)
+
+	objectId := putEntry(t, newTestProvider(t, client, Options{MinUploadSize: 1, SigningKey: "key"}), "0a", "signed content")
+
+	if obj, _ := fake.object("action/0a"); obj.metadata[signatureKey] == "" {
+		t.Fatal("expected uploaded action object to be signed")
+	}
+
+	if got, _, err := newTestProvider(t, client, Options{SigningKey: "key"}).Get(context.Background(), "0a"); err != nil || got != objectId {
+		t.Errorf("got %q, %v, want hit of signed entry", got, err)
+	}
+
+	if got, _, err := newTestProvider(t, client, Options{SigningKey: "other key"}).Get(context.Background(), "0a"); err != nil || got != "" {
+		t.Errorf("got %q, %v, want entry signed with another key to be rejected", got, err)
+	}
+}
+
+func TestUnsignedEntries(t *testing.T) {
+	fake, client := newFakeS3(t)
+
+	putEntry(t, newTestProvider(t, client, Options{MinUploadSize: 1}), "0a", "unsigned content")
+
+	var logs bytes.Buffer
+	p := newTestProvider(t, client, Options{SigningKey: "key"})
+	p.WithLogOutput(&logs)
+
+	if got, _, err := p.Get(context.Background(), "0a"); err != nil || got != "" {
+		t.Errorf("got %q, %v, want unsigned entry to be rejected", got, err)
+	}
+
+	// The entry might be signed with another key, it is not deleted
+	if _, found := fake.object("action/0a"); !found {
+		t.Error("expected unsigned action object to be kept")
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(logs.String(), "rejected 1 action objects without valid signature") {
+		t.Errorf("expected rejected entry to be logged, got %q", logs.String())
+	}
+
+	// Without signing key, the entry is used as is
+	if got, _, _ := newTestProvider(t, client, Options{}).Get(context.Background(), "0a"); got == "" {
+		t.Error("expected unsigned entry to be used without signing key")
+	}
+}
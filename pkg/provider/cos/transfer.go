@@ -0,0 +1,255 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/IBM/ibm-cos-sdk-go/aws"
+	"github.com/IBM/ibm-cos-sdk-go/service/s3"
+	"github.com/homeport/go-cache-prog/pkg/errgroup"
+)
+
+const DefaultMultipartThreshold = 64 << 20
+const DefaultPartSize = 16 << 20
+const DefaultTransferConcurrency = 4
+
+// MinPartSize is the smallest part size supported by S3 compatible storage
+const MinPartSize = 5 << 20
+
+// maxParts is the maximum number of parts of a multipart upload
+const maxParts = 10000
+
+const abortTimeout = 10 * time.Second
+
+const initialUploadBackoff = 500 * time.Millisecond
+
+// part is a range of an object that is transferred on its own
+type part struct {
+	number int64
+	offset int64
+	size   int64
+}
+
+// splitParts splits the object into parts of the part size, the part size
+// is increased for objects that would need more parts than supported
+func splitParts(size int64, partSize int64) []part {
+	if size > partSize*maxParts {
+		partSize = (size + maxParts - 1) / maxParts
+	}
+
+	var parts []part
+	for offset := int64(0); offset < size; offset += partSize {
+		parts = append(parts, part{
+			number: int64(len(parts) + 1),
+			offset: offset,
+			size:   min(partSize, size-offset),
+		})
+	}
+
+	return parts
+}
+
+func (p *provider) multipart(size int64) bool {
+	return p.options.MultipartThreshold > 0 && size >= p.options.MultipartThreshold
+}
+
+// uploadParts uploads a large object using a multipart upload with parallel
+// part uploads. Failed parts are retried on their own, so that a transient
+// error does not restart the whole transfer. If the upload fails anyway, it
+// is aborted, so that no orphaned parts are left behind in the bucket.
+func (p *provider) uploadParts(ctx context.Context, key string, file *os.File, size int64, metadata map[string]*string) error {
+	created, err := p.client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:   &p.bucket,
+		Key:      &key,
+		Metadata: metadata,
+	}, withoutSDKRetries)
+	if err != nil {
+		return err
+	}
+
+	parts := splitParts(size, p.options.PartSize)
+	completed := make([]*s3.CompletedPart, len(parts))
+
+	group, groupCtx := errgroup.New(ctx, p.options.TransferConcurrency)
+	for _, pt := range parts {
+		if groupCtx.Err() != nil {
+			break
+		}
+
+		group.Go(func() error {
+			return p.uploadPart(groupCtx, key, created.UploadId, file, pt, completed)
+		})
+	}
+
+	err = group.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	if err == nil {
+		_, err = p.client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          &p.bucket,
+			Key:             &key,
+			UploadId:        created.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+		}, withoutSDKRetries)
+	}
+
+	if err != nil {
+		// The upload is aborted even if the context is cancelled already
+		abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+		defer cancel()
+
+		if _, abortErr := p.client.AbortMultipartUploadWithContext(abortCtx, &s3.AbortMultipartUploadInput{
+			Bucket:   &p.bucket,
+			Key:      &key,
+			UploadId: created.UploadId,
+		}); abortErr != nil {
+			p.log.Printf("failed to abort multipart upload of %s: %v", key, abortErr)
+		}
+	}
+
+	return err
+}
+
+// uploadPart uploads the part of the file, transient errors are retried
+func (p *provider) uploadPart(ctx context.Context, key string, uploadId *string, file *os.File, pt part, completed []*s3.CompletedPart) error {
+	return retry(ctx, p.options.UploadRetries, initialUploadBackoff, func() error {
+		res, err := p.client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+			Bucket:     &p.bucket,
+			Key:        &key,
+			UploadId:   uploadId,
+			PartNumber: aws.Int64(pt.number),
+
+			Body:          io.NewSectionReader(file, pt.offset, pt.size),
+			ContentLength: aws.Int64(pt.size),
+		}, withoutSDKRetries)
+		if err != nil {
+			return err
+		}
+
+		completed[pt.number-1] = &s3.CompletedPart{
+			ETag:       res.ETag,
+			PartNumber: aws.Int64(pt.number),
+		}
+
+		return nil
+	})
+}
+
+// download downloads a large object into a temporary file using parallel
+// ranged requests, the body of the response that was already received
+// provides the first part. Parts that fail are resumed from where they
+// stopped. The returned reader removes the temporary file when closed.
+func (p *provider) download(ctx context.Context, key string, res *s3.GetObjectOutput) (io.ReadCloser, error) {
+	file, err := os.CreateTemp("", "go-cache-prog-download-*")
+	if err != nil {
+		return nil, err
+	}
+
+	downloaded := &tempFile{File: file}
+
+	parts := splitParts(aws.Int64Value(res.ContentLength), p.options.PartSize)
+
+	group, groupCtx := errgroup.New(ctx, p.options.TransferConcurrency)
+	for _, pt := range parts {
+		if groupCtx.Err() != nil {
+			break
+		}
+
+		// Only the first part starts with the body of the response
+		var body io.ReadCloser
+		if pt.number == 1 {
+			body = res.Body
+		}
+
+		group.Go(func() error {
+			return p.downloadPart(groupCtx, key, res.ETag, file, pt, body)
+		})
+	}
+
+	err = group.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		_ = downloaded.Close()
+		return nil, err
+	}
+
+	return downloaded, nil
+}
+
+// downloadPart downloads the part into the file, starting with the body if
+// provided. Transient errors are retried, resuming after the bytes that
+// were already written.
+func (p *provider) downloadPart(ctx context.Context, key string, etag *string, file *os.File, pt part, body io.ReadCloser) error {
+	var written int64
+	return retry(ctx, p.options.DownloadRetries, initialDownloadBackoff, func() error {
+		if body == nil {
+			// The ETag makes sure that all parts belong to the same object
+			obj, err := p.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+				Bucket:  &p.bucket,
+				Key:     &key,
+				Range:   aws.String(fmt.Sprintf("bytes=%d-%d", pt.offset+written, pt.offset+pt.size-1)),
+				IfMatch: etag,
+			}, withoutSDKRetries)
+			if err != nil {
+				return err
+			}
+
+			body = obj.Body
+		}
+
+		defer func() {
+			_ = body.Close()
+			body = nil
+		}()
+
+		n, err := io.Copy(io.NewOffsetWriter(file, pt.offset+written), io.LimitReader(body, pt.size-written))
+		written += n
+		switch {
+		case err != nil:
+			return err
+
+		case written < pt.size:
+			return io.ErrUnexpectedEOF
+		}
+
+		return nil
+	})
+}
+
+// tempFile is a temporary file that is removed when it is closed
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	defer func() { _ = os.Remove(f.Name()) }()
+	return f.File.Close()
+}
@@ -0,0 +1,132 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestSplitParts(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int64
+		partSize int64
+		parts    int
+		last     int64
+	}{
+		{name: "single part", size: 10, partSize: 10, parts: 1, last: 10},
+		{name: "partial last part", size: 25, partSize: 10, parts: 3, last: 5},
+		{name: "too many parts", size: 2 * maxParts * 10, partSize: 10, parts: maxParts, last: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := splitParts(tt.size, tt.partSize)
+			if len(parts) != tt.parts {
+				t.Fatalf("got %d parts, want %d", len(parts), tt.parts)
+			}
+
+			var offset int64
+			for i, pt := range parts {
+				if pt.number != int64(i+1) || pt.offset != offset {
+					t.Fatalf("got part %+v at index %d, want offset %d", pt, i, offset)
+				}
+
+				offset += pt.size
+			}
+
+			if offset != tt.size || parts[len(parts)-1].size != tt.last {
+				t.Errorf("got parts covering %d bytes with last part of %d bytes", offset, parts[len(parts)-1].size)
+			}
+		})
+	}
+}
+
+// largeContent returns content that is transferred in three parts
+func largeContent() string {
+	return string(bytes.Repeat([]byte("0123456789abcdef"), 2*MinPartSize/16+1))
+}
+
+func TestMultipartTransfer(t *testing.T) {
+	fake, client := newFakeS3(t)
+
+	options := Options{MultipartThreshold: MinPartSize, PartSize: MinPartSize, Layout: LayoutAction}
+	content := largeContent()
+
+	// The failed part is retried on its own
+	fake.fail(http.MethodPut, 1, http.StatusServiceUnavailable)
+	putEntry(t, newTestProvider(t, client, options), "0a", content)
+
+	if obj, _ := fake.object("action/0a"); string(obj.body) != content || obj.metadata[objectIdKey] != outputId(content) {
+		t.Fatal("expected the multipart upload to be completed with the content and metadata")
+	}
+
+	if puts := fake.count(http.MethodPut, "action/0a"); puts != 4 {
+		t.Errorf("got %d part uploads, want 4 (three parts and one retry)", puts)
+	}
+
+	// The first download provides the first part, which is cut off only
+	// after it, the second download is a ranged request of another part,
+	// which is resumed
+	fake.truncate(2)
+
+	p := newTestProvider(t, client, options)
+	got, diskpath, err := p.Get(context.Background(), "0a")
+	if err != nil || got != outputId(content) {
+		t.Fatalf("got %q, %v, want hit", got, err)
+	}
+
+	if data, err := os.ReadFile(diskpath); err != nil || string(data) != content {
+		t.Errorf("unexpected downloaded content, %v", err)
+	}
+
+	if gets := fake.count(http.MethodGet, "action/0a"); gets != 4 {
+		t.Errorf("got %d downloads, want 4 (three parts and one resumed part)", gets)
+	}
+}
+
+func TestMultipartUploadAborted(t *testing.T) {
+	fake, client := newFakeS3(t)
+	fake.fail(http.MethodPut, 10, http.StatusForbidden)
+
+	options := Options{MultipartThreshold: MinPartSize, PartSize: MinPartSize, Layout: LayoutAction}
+	putEntry(t, newTestProvider(t, client, options), "0a", largeContent())
+
+	if _, found := fake.object("action/0a"); found {
+		t.Error("expected the failed upload not to create an object")
+	}
+
+	if aborts := fake.count(http.MethodDelete, "action/0a"); aborts != 1 || len(fake.uploads) != 0 {
+		t.Errorf("expected the failed multipart upload to be aborted, got %d aborts", aborts)
+	}
+}
+
+func TestPartSize(t *testing.T) {
+	_, client := newFakeS3(t)
+
+	if _, err := NewProviderWithClient(client, "bucket", Options{CacheDir: t.TempDir(), PartSize: MinPartSize - 1}); err == nil {
+		t.Error("expected part size below the minimum to fail")
+	}
+}
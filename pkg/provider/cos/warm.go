@@ -0,0 +1,235 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/ibm-cos-sdk-go/aws"
+	"github.com/IBM/ibm-cos-sdk-go/service/s3"
+	"github.com/homeport/go-cache-prog/pkg/errgroup"
+)
+
+// WarmOptions select the entries that are downloaded by Warm
+type WarmOptions struct {
+	// Prefix of the action ids of the entries
+	Prefix string
+
+	// Since selects entries that were uploaded within the duration
+	Since time.Duration
+
+	// Limit is the maximum number of entries, the most recently uploaded
+	// entries are selected first (default no limit)
+	Limit int
+
+	// Manifest selects the entries of the configured manifest instead of
+	// the entries of the bucket
+	Manifest bool
+
+	// Workers is the number of concurrent downloads
+	Workers int
+}
+
+// WarmResult summarizes the entries downloaded by Warm, entries are skipped
+// while the circuit breaker is open
+type WarmResult struct {
+	Downloaded int
+	Cached     int
+	Missing    int
+	Skipped    int
+	Failed     int
+	Bytes      int64
+	Duration   time.Duration
+}
+
+// Warm downloads the selected entries of the bucket into the local cache
+// directory, e.g. to populate the cache of a fresh CI container before the
+// build starts. Entries that are in the local cache directory already are
+// skipped.
+func (p *provider) Warm(ctx context.Context, options WarmOptions) (WarmResult, error) {
+	start := time.Now()
+
+	actionIds, err := p.warmActionIds(ctx, options)
+	if err != nil {
+		return WarmResult{}, err
+	}
+
+	var downloaded, cached, missing, skipped, failed, bytes atomic.Int64
+
+	group, groupCtx := errgroup.New(ctx, options.Workers)
+	for _, actionId := range actionIds {
+		if groupCtx.Err() != nil {
+			break
+		}
+
+		group.Go(func() error {
+			if objectId, _, err := p.localProvider.Get(actionId); err == nil && objectId != "" {
+				cached.Add(1)
+				return nil
+			}
+
+			if !p.breaker.allow() {
+				skipped.Add(1)
+				return nil
+			}
+
+			objectId, diskpath, err := p.getRemote(groupCtx, actionId)
+			switch {
+			case err != nil:
+				failed.Add(1)
+				p.log.Printf("failed to download action %s: %v", actionId, err)
+
+			// A download cancelled by the circuit breaker is reported as
+			// miss, but the entry was not looked up
+			case objectId == "" && !p.breaker.allow():
+				skipped.Add(1)
+
+			case objectId == "":
+				missing.Add(1)
+
+			default:
+				downloaded.Add(1)
+				if fi, err := os.Stat(diskpath); err == nil {
+					bytes.Add(fi.Size())
+				}
+			}
+
+			return nil
+		})
+	}
+
+	_ = group.Wait()
+
+	result := WarmResult{
+		Downloaded: int(downloaded.Load()),
+		Cached:     int(cached.Load()),
+		Missing:    int(missing.Load()),
+		Skipped:    int(skipped.Load()),
+		Failed:     int(failed.Load()),
+		Bytes:      bytes.Load(),
+		Duration:   time.Since(start),
+	}
+
+	switch {
+	case result.Failed > 0:
+		return result, fmt.Errorf("failed to download %d entries", result.Failed)
+
+	case result.Skipped > 0:
+		return result, fmt.Errorf("skipped %d entries, the remote storage is unavailable", result.Skipped)
+	}
+
+	return result, ctx.Err()
+}
+
+// warmActionIds returns the action ids of the selected entries
+func (p *provider) warmActionIds(ctx context.Context, options WarmOptions) ([]string, error) {
+	if options.Manifest {
+		return p.warmManifestActionIds(ctx, options)
+	}
+
+	type entry struct {
+		actionId string
+		modified time.Time
+	}
+
+	var (
+		entries []entry
+		since   = time.Now().Add(-options.Since)
+	)
+
+	err := p.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: &p.bucket,
+		Prefix: ptr(p.actionKey(options.Prefix)),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			if obj.Key == nil {
+				continue
+			}
+
+			actionId := strings.TrimPrefix(*obj.Key, p.actionKey(""))
+			if _, err := hex.DecodeString(actionId); err != nil || actionId == "" {
+				continue
+			}
+
+			modified := aws.TimeValue(obj.LastModified)
+			if options.Since > 0 && modified.Before(since) {
+				continue
+			}
+
+			entries = append(entries, entry{actionId: actionId, modified: modified})
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return b.modified.Compare(a.modified)
+	})
+
+	if options.Limit > 0 && len(entries) > options.Limit {
+		entries = entries[:options.Limit]
+	}
+
+	actionIds := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actionIds = append(actionIds, entry.actionId)
+	}
+
+	return actionIds, nil
+}
+
+func (p *provider) warmManifestActionIds(ctx context.Context, options WarmOptions) ([]string, error) {
+	switch {
+	case p.options.ManifestFile == "" && p.options.ManifestKey == "":
+		return nil, fmt.Errorf("no manifest file or manifest key is configured")
+
+	case options.Since > 0:
+		return nil, fmt.Errorf("entries of the manifest cannot be selected by age")
+	}
+
+	manifestIds, err := p.readManifest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", p.manifestName(), err)
+	}
+
+	var actionIds []string
+	for _, actionId := range manifestIds {
+		if strings.HasPrefix(actionId, options.Prefix) {
+			actionIds = append(actionIds, actionId)
+		}
+	}
+
+	if options.Limit > 0 && len(actionIds) > options.Limit {
+		actionIds = actionIds[:options.Limit]
+	}
+
+	return actionIds, nil
+}
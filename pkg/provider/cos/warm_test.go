@@ -0,0 +1,172 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// putEntries puts the entries using the provider, and closes the provider to
+// wait for the uploads
+func putEntries(t *testing.T, p *provider, count int) []string {
+	t.Helper()
+
+	var actionIds []string
+	for i := range count {
+		actionId := fmt.Sprintf("%02x", i)
+		if _, _, err := p.Get(context.Background(), actionId); err != nil {
+			t.Fatal(err)
+		}
+
+		content := fmt.Sprintf("build output %d", i)
+		if _, err := p.Put(context.Background(), actionId, outputId(content), strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+
+		actionIds = append(actionIds, actionId)
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return actionIds
+}
+
+func TestWarm(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeS3(t)
+
+	putEntries(t, newTestProvider(t, client, Options{MinUploadSize: 1}), 12)
+
+	// The first entry was uploaded long ago
+	fake.mutex.Lock()
+	obj := fake.objects["action/00"]
+	obj.modified = time.Now().Add(-48 * time.Hour)
+	fake.objects["action/00"] = obj
+	fake.mutex.Unlock()
+
+	tests := []struct {
+		name    string
+		options WarmOptions
+		want    WarmResult
+	}{
+		{
+			name:    "prefix",
+			options: WarmOptions{Prefix: "0a", Workers: 4},
+			want:    WarmResult{Downloaded: 1},
+		},
+		{
+			name:    "since",
+			options: WarmOptions{Since: time.Hour, Workers: 4},
+			want:    WarmResult{Downloaded: 10, Cached: 1},
+		},
+		{
+			name:    "limit",
+			options: WarmOptions{Limit: 11, Workers: 4},
+			want:    WarmResult{Cached: 11},
+		},
+		{
+			name:    "all",
+			options: WarmOptions{Workers: 4},
+			want:    WarmResult{Downloaded: 1, Cached: 11},
+		},
+	}
+
+	// The entries are warmed into the same local cache directory one after
+	// the other, entries that were downloaded already are cached
+	p := newTestProvider(t, client, Options{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Warm(ctx, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Downloaded != tt.want.Downloaded || got.Cached != tt.want.Cached || got.Missing != 0 || got.Skipped != 0 || got.Failed != 0 {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+
+			if got.Downloaded > 0 && got.Bytes == 0 {
+				t.Error("expected downloaded bytes")
+			}
+		})
+	}
+}
+
+func TestWarmManifest(t *testing.T) {
+	ctx := context.Background()
+	fake, client := newFakeS3(t)
+
+	actionIds := putEntries(t, newTestProvider(t, client, Options{MinUploadSize: 1, ManifestKey: "main"}), 12)
+
+	p := newTestProvider(t, client, Options{ManifestKey: "main"})
+	got, err := p.Warm(ctx, WarmOptions{Manifest: true, Limit: 5, Workers: 2})
+	if err != nil || got.Downloaded != 5 {
+		t.Fatalf("got %+v, %v, want 5 downloads", got, err)
+	}
+
+	if _, err := p.Warm(ctx, WarmOptions{Manifest: true, Since: time.Hour}); err == nil {
+		t.Error("expected entries of the manifest not to be selected by age")
+	}
+
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Warming is no session of the toolchain, the manifest is unchanged
+	if obj, _ := fake.object("manifest/main"); len(parseManifest(obj.body)) != len(actionIds) {
+		t.Errorf("expected manifest with %d entries", len(actionIds))
+	}
+
+	if _, err := newTestProvider(t, client, Options{}).Warm(ctx, WarmOptions{Manifest: true}); err == nil {
+		t.Error("expected warming without manifest to fail")
+	}
+}
+
+func TestWarmSkipped(t *testing.T) {
+	ctx := context.Background()
+	_, client := newFakeS3(t)
+
+	putEntries(t, newTestProvider(t, client, Options{MinUploadSize: 1}), 4)
+
+	// Entries are not looked up while the circuit breaker is open, so they
+	// are neither downloaded nor missing
+	p := newTestProvider(t, client, Options{CircuitBreakerCooldown: time.Hour})
+	p.breaker.tripAtStart(errUnavailable)
+
+	got, err := p.Warm(ctx, WarmOptions{Workers: 2})
+	if err == nil {
+		t.Error("expected skipped entries to be reported")
+	}
+
+	if got.Skipped != 4 || got.Missing != 0 || got.Downloaded != 0 {
+		t.Errorf("got %+v, want 4 skipped entries", got)
+	}
+
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
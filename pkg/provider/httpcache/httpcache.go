@@ -0,0 +1,522 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package httpcache provides a cache provider for HTTP cache servers, that
+// store action entries under /ac/<actionId> and objects under /cas/<objectId>,
+// e.g. bazel-remote (with disabled action cache validation) or WebDAV.
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/homeport/go-cache-prog/pkg/cache"
+	"github.com/homeport/go-cache-prog/pkg/provider/cos"
+	"github.com/homeport/go-cache-prog/pkg/provider/internal/transfer"
+	"github.com/homeport/go-cache-prog/pkg/provider/local"
+)
+
+const DefaultTimeout = 30 * time.Second
+const DefaultDownloadRetries = 2
+
+const initialDownloadBackoff = 100 * time.Millisecond
+
+type provider struct {
+	config  Config
+	client  *http.Client
+	baseURL *url.URL
+
+	localProvider cache.Provider
+	uploads       *transfer.UploadQueue
+
+	log *log.Logger
+}
+
+type Config struct {
+	HTTP HTTP `json:"http"`
+
+	CacheDir      string `json:"cache_dir"`
+	MinUploadSize int64  `json:"min_upload_size"`
+	Fsync         bool   `json:"fsync"`
+
+	VerifyPuts         bool `json:"verify_puts"`
+	SkipDownloadVerify bool `json:"skip_download_verify"`
+
+	MaxSize int64         `json:"max_size"`
+	MaxAge  time.Duration `json:"max_age"`
+
+	// ReadOnly stores entries in the local cache directory only and never
+	// writes to the HTTP cache server
+	ReadOnly bool `json:"read_only"`
+
+	// Uploads run in the background using a limited number of concurrent
+	// uploads, the drop policy applies when the backlog is full. Requests
+	// failing with a transient error, e.g. a server error, are retried, a
+	// negative number of retries disables retrying.
+	UploadConcurrency int    `json:"upload_concurrency"`
+	UploadBacklog     int    `json:"upload_backlog"`
+	UploadRetries     int    `json:"upload_retries"`
+	UploadDropPolicy  string `json:"upload_drop_policy"`
+	DownloadRetries   int    `json:"download_retries"`
+}
+
+// UnmarshalJSON rejects unknown fields, so that options of other providers,
+// e.g. the encryption key of the cos command, are not silently ignored
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type config Config
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode((*config)(c))
+}
+
+type HTTP struct {
+	URL string `json:"url"`
+
+	// Authentication, either using a bearer token, basic auth, or custom
+	// headers that are added to every request
+	BearerToken string            `json:"bearer_token"`
+	Username    string            `json:"username"`
+	Password    string            `json:"password"`
+	Headers     map[string]string `json:"headers"`
+
+	// ReadOnlyBearerToken is used instead of the bearer token in read-only
+	// mode, so that read-only jobs do not need a token with write access
+	ReadOnlyBearerToken string `json:"read_only_bearer_token"`
+
+	CACertFile         string `json:"ca_cert_file"`
+	ClientCertFile     string `json:"client_cert_file"`
+	ClientKeyFile      string `json:"client_key_file"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+
+	// Timeout of requests, requests with a body may take longer, as long as
+	// the body is transferred at least at the minimum transfer rate (bytes
+	// per second)
+	Timeout         time.Duration `json:"timeout"`
+	MinTransferRate int64         `json:"min_transfer_rate"`
+}
+
+var _ cache.ContextProvider = &provider{}
+
+func NewProvider(config Config) (*provider, error) {
+	if config.CacheDir == "" {
+		return nil, fmt.Errorf("cache directory cannot be empty")
+	}
+
+	if config.MinUploadSize <= 0 {
+		config.MinUploadSize = cos.DefaultMinUploadSize
+	}
+
+	if config.HTTP.Timeout == 0 {
+		config.HTTP.Timeout = DefaultTimeout
+	}
+
+	if config.DownloadRetries == 0 {
+		config.DownloadRetries = DefaultDownloadRetries
+	}
+
+	if config.ReadOnly && config.HTTP.ReadOnlyBearerToken != "" {
+		config.HTTP.BearerToken = config.HTTP.ReadOnlyBearerToken
+	}
+
+	baseURL, err := url.Parse(strings.TrimSuffix(config.HTTP.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", config.HTTP.URL, err)
+	}
+
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid URL %q, only http and https are supported", config.HTTP.URL)
+	}
+
+	tlsConfig, err := newTLSConfig(config.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	localProvider, err := local.NewProvider(config.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := log.New(io.Discard, "", log.LstdFlags)
+
+	localProvider.
+		WithSync(config.Fsync).
+		WithVerify(config.VerifyPuts).
+		WithMaxSize(config.MaxSize).
+		WithMaxAge(config.MaxAge).
+		WithLogger(logger)
+
+	uploads, err := transfer.NewUploadQueue(transfer.QueueOptions{
+		Concurrency: config.UploadConcurrency,
+		Backlog:     config.UploadBacklog,
+		DropPolicy:  config.UploadDropPolicy,
+		Retries:     config.UploadRetries,
+		Transient:   isTransient,
+	}, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &provider{
+		config:        config,
+		baseURL:       baseURL,
+		client:        transfer.NewHTTPClientWithTransport(transport, config.HTTP.Timeout, config.HTTP.MinTransferRate),
+		localProvider: localProvider,
+		uploads:       uploads,
+		log:           logger,
+	}, nil
+}
+
+func newTLSConfig(config HTTP) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify, // #nosec G402 - explicitly requested by the user
+	}
+
+	if config.CACertFile != "" {
+		data, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("failed to parse certificates from %s", config.CACertFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.ClientCertFile != "" || config.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// WithLogOutput configures where failed requests to the HTTP cache server,
+// failed uploads, and the trimming of the local cache directory are logged
+func (p *provider) WithLogOutput(w io.Writer) *provider {
+	p.SetLogOutput(w)
+	return p
+}
+
+// SetLogOutput is like WithLogOutput, but can be used by wrapping providers
+func (p *provider) SetLogOutput(w io.Writer) {
+	p.log.SetOutput(w)
+}
+
+func (p *provider) actionURL(actionId string) string {
+	return p.baseURL.JoinPath("ac", actionId).String()
+}
+
+func (p *provider) objectURL(objectId string) string {
+	return p.baseURL.JoinPath("cas", objectId).String()
+}
+
+func (p *provider) KnownCommands() []string {
+	return []string{"get", "put", "close"}
+}
+
+func (p *provider) Get(ctx context.Context, actionId string) (string, string, error) {
+	objectId, diskpath, err := p.localProvider.Get(actionId)
+	if err != nil {
+		return failure(err)
+	}
+
+	if objectId != "" && diskpath != "" {
+		return objectId, diskpath, nil
+	}
+
+	// --- --- ---
+
+	res, err := p.get(ctx, p.actionURL(actionId))
+	if err != nil {
+		p.log.Printf("failed to get action entry %s: %v", actionId, err)
+		return notFound()
+	}
+
+	if res == nil {
+		return notFound()
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, local.MaxActionEntrySize))
+	_ = res.Body.Close()
+	if err != nil {
+		p.log.Printf("failed to read action entry %s: %v", actionId, err)
+		return notFound()
+	}
+
+	objectId, size, ok := local.ParseActionEntry(data)
+	if !ok {
+		p.log.Printf("invalid action entry %s: %q", actionId, data)
+		return notFound()
+	}
+
+	res, err = p.get(ctx, p.objectURL(objectId))
+	if err != nil {
+		p.log.Printf("failed to get object %s: %v", objectId, err)
+		return notFound()
+	}
+
+	if res == nil {
+		p.log.Printf("action entry %s references missing object %s", actionId, objectId)
+		return notFound()
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.ContentLength >= 0 && res.ContentLength != size {
+		p.log.Printf("object %s has size %d, but expected %d", objectId, res.ContentLength, size)
+		return notFound()
+	}
+
+	var body io.Reader = res.Body
+	if !p.config.SkipDownloadVerify {
+		body = cache.NewVerifyingReader(body, objectId)
+	}
+
+	diskpath, err = p.localProvider.Put(actionId, objectId, body)
+	if err != nil {
+		p.log.Printf("failed to download object %s: %v", objectId, err)
+		return notFound()
+	}
+
+	return objectId, diskpath, nil
+}
+
+func (p *provider) Put(ctx context.Context, actionId string, objectId string, body io.Reader) (string, error) {
+	diskpath, err := p.localProvider.Put(actionId, objectId, body)
+	if err != nil {
+		return "", err
+	}
+
+	// --- --- ---
+
+	fi, err := os.Stat(diskpath)
+	if err != nil {
+		return "", err
+	}
+
+	size := fi.Size()
+	if size < p.config.MinUploadSize || p.config.ReadOnly {
+		return diskpath, nil
+	}
+
+	// The build continues with the local object, while the upload waits in
+	// the backlog of the upload queue for a free worker
+	p.uploads.Enqueue(transfer.Job{
+		ActionId: actionId,
+		Upload: func(ctx context.Context) error {
+			return p.upload(ctx, actionId, objectId, diskpath, size)
+		},
+	})
+
+	return diskpath, nil
+}
+
+// upload stores the object, unless it already exists, and afterwards the
+// action entry, so that the action entry never references a missing object
+func (p *provider) upload(ctx context.Context, actionId string, objectId string, diskpath string, size int64) error {
+	res, err := p.do(ctx, http.MethodHead, p.objectURL(objectId), nil, 0)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+
+	case http.StatusNotFound:
+		file, err := os.Open(diskpath) // #nosec G304 - local provider takes care of filepath clean call
+		if err != nil {
+			return err
+		}
+		defer func() { _ = file.Close() }()
+
+		if err := p.put(ctx, p.objectURL(objectId), file, size); err != nil {
+			return err
+		}
+
+	default:
+		return unexpectedStatus(res)
+	}
+
+	entry := local.FormatActionEntry(objectId, size)
+	return p.put(ctx, p.actionURL(actionId), strings.NewReader(entry), int64(len(entry)))
+}
+
+func (p *provider) put(ctx context.Context, target string, body io.Reader, size int64) error {
+	res, err := p.do(ctx, http.MethodPut, target, body, size)
+	if err != nil {
+		return err
+	}
+
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return unexpectedStatus(res)
+	}
+
+	return nil
+}
+
+// get returns the response of a successful GET request, or no response if
+// the entry does not exist. Other responses, e.g. due to missing
+// permissions, are errors, so that they are not mistaken for a miss.
+// Transient errors are retried.
+func (p *provider) get(ctx context.Context, target string) (*http.Response, error) {
+	var found *http.Response
+	err := transfer.Retry(ctx, p.config.DownloadRetries, initialDownloadBackoff, isTransient, func() error {
+		res, err := p.do(ctx, http.MethodGet, target, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		switch res.StatusCode {
+		case http.StatusOK:
+			found = res
+			return nil
+
+		case http.StatusNotFound:
+			_ = res.Body.Close()
+			return nil
+
+		default:
+			_ = res.Body.Close()
+			return unexpectedStatus(res)
+		}
+	})
+
+	return found, err
+}
+
+func (p *provider) do(ctx context.Context, method string, target string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	for key, val := range p.config.HTTP.Headers {
+		req.Header.Set(key, val)
+	}
+
+	switch {
+	case p.config.HTTP.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+p.config.HTTP.BearerToken)
+
+	case p.config.HTTP.Username != "":
+		req.SetBasicAuth(p.config.HTTP.Username, p.config.HTTP.Password)
+	}
+
+	return p.client.Do(req)
+}
+
+func (p *provider) Close(ctx context.Context) error {
+	// Closing the local provider trims the cache directory, which must not
+	// remove objects that are still waiting to be uploaded
+	err := p.uploads.Close(ctx)
+	if summary := p.uploads.Summary(); summary != "" {
+		p.log.Printf("uploads to %s: %s", p.baseURL.Redacted(), summary)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := p.localProvider.Close(); err != nil {
+		return err
+	}
+
+	p.client.CloseIdleConnections()
+	return nil
+}
+
+// statusError is a response with an unexpected status
+type statusError struct {
+	status     string
+	statusCode int
+	method     string
+	url        string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected response status %q for %s %s", e.status, e.method, e.url)
+}
+
+func unexpectedStatus(res *http.Response) error {
+	return &statusError{
+		status:     res.Status,
+		statusCode: res.StatusCode,
+		method:     res.Request.Method,
+		url:        res.Request.URL.Redacted(),
+	}
+}
+
+// isTransient checks whether a request might succeed when it is retried,
+// i.e. it failed with a server error, was throttled, or failed on the
+// network, but was not cancelled
+func isTransient(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode >= 500 || statusErr.statusCode == http.StatusTooManyRequests
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, transfer.ErrTimeout)
+}
+
+func notFound() (string, string, error) {
+	return "", "", nil
+}
+
+func failure(err error) (string, string, error) {
+	return "", "", err
+}
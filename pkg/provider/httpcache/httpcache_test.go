@@ -0,0 +1,323 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// cacheServer is an in-memory HTTP cache server, that requires the bearer
+// token for all requests
+type cacheServer struct {
+	token string
+
+	mutex   sync.Mutex
+	entries map[string][]byte
+
+	// failures are the statuses of the next responses, regardless of the
+	// request
+	failures []int
+}
+
+func newCacheServer(t *testing.T, token string) (*cacheServer, *httptest.Server) {
+	t.Helper()
+
+	cs := &cacheServer{token: token, entries: map[string][]byte{}}
+	server := httptest.NewServer(cs)
+	t.Cleanup(server.Close)
+
+	return cs, server
+}
+
+func (cs *cacheServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+cs.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if len(cs.failures) > 0 {
+		w.WriteHeader(cs.failures[0])
+		cs.failures = cs.failures[1:]
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		data, ok := cs.entries[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(data)
+
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		cs.entries[r.URL.Path] = data
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (cs *cacheServer) get(path string) ([]byte, bool) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	data, ok := cs.entries[path]
+	return data, ok
+}
+
+func (cs *cacheServer) fail(statuses ...int) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	cs.failures = append(cs.failures, statuses...)
+}
+
+func (cs *cacheServer) set(path string, data []byte) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	cs.entries[path] = data
+}
+
+func newTestProvider(t *testing.T, url string, token string) (*provider, *bytes.Buffer) {
+	t.Helper()
+
+	provider, err := NewProvider(Config{
+		HTTP:          HTTP{URL: url, BearerToken: token},
+		CacheDir:      t.TempDir(),
+		MinUploadSize: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log bytes.Buffer
+	provider.WithLogOutput(&log)
+
+	return provider, &log
+}
+
+func objectIdOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestGetHit(t *testing.T) {
+	cs, server := newCacheServer(t, "secret")
+
+	content := []byte("cached build output")
+	objectId := objectIdOf(content)
+	cs.set("/ac/action", []byte(objectId+":19\n"))
+	cs.set("/cas/"+objectId, content)
+
+	provider, log := newTestProvider(t, server.URL, "secret")
+
+	gotObjectId, diskpath, err := provider.Get(context.Background(), "action")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotObjectId != objectId {
+		t.Fatalf("got object id %q, want %q (log: %s)", gotObjectId, objectId, log)
+	}
+
+	data, err := os.ReadFile(diskpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, content) {
+		t.Errorf("got content %q, want %q", data, content)
+	}
+}
+
+func TestGetMiss(t *testing.T) {
+	_, server := newCacheServer(t, "secret")
+	provider, log := newTestProvider(t, server.URL, "secret")
+
+	objectId, diskpath, err := provider.Get(context.Background(), "action")
+	if err != nil || objectId != "" || diskpath != "" {
+		t.Fatalf("expected a miss, got %q, %q, %v", objectId, diskpath, err)
+	}
+
+	if log.Len() != 0 {
+		t.Errorf("expected a miss not to be logged, got: %s", log)
+	}
+}
+
+func TestAuthFailure(t *testing.T) {
+	cs, server := newCacheServer(t, "secret")
+	provider, log := newTestProvider(t, server.URL, "wrong")
+
+	objectId, diskpath, err := provider.Get(context.Background(), "action")
+	if err != nil || objectId != "" || diskpath != "" {
+		t.Fatalf("expected a miss, got %q, %q, %v", objectId, diskpath, err)
+	}
+
+	if !strings.Contains(log.String(), "401 Unauthorized") {
+		t.Errorf("expected the unauthorized response to be logged, got: %s", log)
+	}
+
+	content := []byte("build output")
+	if _, err := provider.Put(context.Background(), "action", objectIdOf(content), bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := provider.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cs.get("/ac/action"); ok {
+		t.Errorf("expected no action entry to be stored")
+	}
+
+	if !strings.Contains(log.String(), "failed to upload action action") || !strings.Contains(log.String(), "0 succeeded, 1 failed") {
+		t.Errorf("expected the failed upload to be logged, got: %s", log)
+	}
+}
+
+func TestPutGetRoundTrip(t *testing.T) {
+	cs, server := newCacheServer(t, "secret")
+
+	content := []byte("build output to be shared")
+	objectId := objectIdOf(content)
+
+	writer, _ := newTestProvider(t, server.URL, "secret")
+	if _, err := writer.Put(context.Background(), "action", objectId, bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, _ := cs.get("/ac/action"); string(entry) != objectId+":25" {
+		t.Fatalf("got action entry %q", entry)
+	}
+
+	reader, log := newTestProvider(t, server.URL, "secret")
+	gotObjectId, diskpath, err := reader.Get(context.Background(), "action")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotObjectId != objectId {
+		t.Fatalf("got object id %q, want %q (log: %s)", gotObjectId, objectId, log)
+	}
+
+	data, err := os.ReadFile(diskpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, content) {
+		t.Errorf("got content %q, want %q", data, content)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	cs, server := newCacheServer(t, "secret")
+
+	content := []byte("build output to be shared")
+	objectId := objectIdOf(content)
+
+	// Server errors and throttled requests are retried, both for uploads and
+	// downloads
+	writer, log := newTestProvider(t, server.URL, "secret")
+	cs.fail(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	if _, err := writer.Put(context.Background(), "action", objectId, bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(log.String(), "1 succeeded, 0 failed") {
+		t.Errorf("expected upload to succeed after retries, got: %s", log)
+	}
+
+	reader, log := newTestProvider(t, server.URL, "secret")
+	cs.fail(http.StatusBadGateway, http.StatusInternalServerError)
+	if gotObjectId, _, err := reader.Get(context.Background(), "action"); err != nil || gotObjectId != objectId {
+		t.Fatalf("got %q, %v, want hit after retries (log: %s)", gotObjectId, err, log)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "server error", err: &statusError{statusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "throttled", err: &statusError{statusCode: http.StatusTooManyRequests}, want: true},
+		{name: "forbidden", err: &statusError{statusCode: http.StatusForbidden}, want: false},
+		{name: "truncated body", err: io.ErrUnexpectedEOF, want: true},
+		{name: "cancelled", err: context.Canceled, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigRejectsUnknownFields(t *testing.T) {
+	var config Config
+	if err := json.Unmarshal([]byte(`{"cache_dir": "/tmp/go-cache", "http": {"url": "http://localhost"}}`), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.CacheDir != "/tmp/go-cache" || config.HTTP.URL != "http://localhost" {
+		t.Errorf("got %+v", config)
+	}
+
+	if err := json.Unmarshal([]byte(`{"encryption_key": "secret"}`), &config); err == nil {
+		t.Error("expected option of another provider to be rejected")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultMinTransferRate = 1 << 20
+
+// ErrTimeout is returned by requests of clients created by NewHTTPClient
+// that did not finish within their timeout, it is a transient error
+var ErrTimeout = errors.New("transfer timed out")
+
+// NewHTTPClient creates the HTTP client for the remote storage. Instead of a
+// fixed timeout for the whole request, each request may take the timeout
+// plus the time it takes to transfer its body at the minimum transfer rate,
+// so that large objects can be transferred, while an unresponsive remote
+// storage is still detected quickly.
+func NewHTTPClient(timeout time.Duration, minTransferRate int64) *http.Client {
+	return NewHTTPClientWithTransport(http.DefaultTransport.(*http.Transport).Clone(), timeout, minTransferRate)
+}
+
+// NewHTTPClientWithTransport is like NewHTTPClient, but sends the requests
+// using the given transport, e.g. one with a custom TLS configuration
+func NewHTTPClientWithTransport(transport *http.Transport, timeout time.Duration, minTransferRate int64) *http.Client {
+	if minTransferRate <= 0 {
+		minTransferRate = DefaultMinTransferRate
+	}
+
+	return &http.Client{
+		Transport: &timeoutTransport{
+			base:            transport,
+			timeout:         timeout,
+			minTransferRate: minTransferRate,
+		},
+	}
+}
+
+type timeoutTransport struct {
+	base            *http.Transport
+	timeout         time.Duration
+	minTransferRate int64
+}
+
+func (t *timeoutTransport) timeoutFor(contentLength int64) time.Duration {
+	if contentLength <= 0 {
+		return t.timeout
+	}
+
+	return t.timeout + time.Duration(contentLength/t.minTransferRate)*time.Second
+}
+
+// RoundTrip sends the request within the timeout for the request body, the
+// timeout for the response body starts once the response headers arrived
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+
+	var expired atomic.Bool
+	timer := time.AfterFunc(t.timeoutFor(req.ContentLength), func() {
+		expired.Store(true)
+		cancel()
+	})
+
+	res, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		timer.Stop()
+		cancel()
+
+		if expired.Load() {
+			return nil, fmt.Errorf("%w after %v: %v", ErrTimeout, t.timeoutFor(req.ContentLength), err)
+		}
+
+		return nil, err
+	}
+
+	if timer.Stop() {
+		timer.Reset(t.timeoutFor(res.ContentLength))
+	}
+
+	res.Body = &timeoutBody{
+		ReadCloser: res.Body,
+		expired:    &expired,
+		stop: func() {
+			timer.Stop()
+			cancel()
+		},
+	}
+
+	return res, nil
+}
+
+func (t *timeoutTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// timeoutBody reports reads that failed due to the timeout as such, so that
+// they are not mistaken for a cancelled request
+type timeoutBody struct {
+	io.ReadCloser
+	expired *atomic.Bool
+	stop    func()
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && b.expired.Load() {
+		return n, fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	defer b.stop()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,94 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transfer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutFor(t *testing.T) {
+	transport := &timeoutTransport{timeout: time.Second, minTransferRate: 1 << 20}
+
+	tests := []struct {
+		contentLength int64
+		timeout       time.Duration
+	}{
+		{contentLength: -1, timeout: time.Second},
+		{contentLength: 0, timeout: time.Second},
+		{contentLength: 1 << 10, timeout: time.Second},
+		{contentLength: 10 << 20, timeout: 11 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if timeout := transport.timeoutFor(tt.contentLength); timeout != tt.timeout {
+			t.Errorf("content length %d: got %v, want %v", tt.contentLength, timeout, tt.timeout)
+		}
+	}
+}
+
+func TestHTTPClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow-headers" {
+			time.Sleep(300 * time.Millisecond)
+		}
+
+		w.Header().Set("Content-Length", "10")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		if r.URL.Path == "/slow-body" {
+			time.Sleep(300 * time.Millisecond)
+		}
+
+		_, _ = io.WriteString(w, "0123456789")
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(100*time.Millisecond, 0)
+
+	tests := []struct {
+		path    string
+		timeout bool
+	}{
+		{path: "/"},
+		{path: "/slow-headers", timeout: true},
+		{path: "/slow-body", timeout: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			res, err := client.Get(server.URL + tt.path)
+			if err == nil {
+				_, err = io.ReadAll(res.Body)
+				_ = res.Body.Close()
+			}
+
+			if timeout := errors.Is(err, ErrTimeout); timeout != tt.timeout {
+				t.Errorf("got %v, want timeout %v", err, tt.timeout)
+			}
+		})
+	}
+}
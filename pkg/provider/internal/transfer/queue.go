@@ -0,0 +1,323 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package transfer provides the parts of transferring build outputs to a
+// remote cache, which are shared by the remote providers.
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultUploadConcurrency = 8
+const DefaultUploadBacklog = 1024
+const DefaultUploadRetries = 3
+
+// Drop policies of the upload queue, used when the backlog is full
+const (
+	// DropNewest drops the upload that does not fit into the backlog
+	DropNewest = "newest"
+
+	// DropOldest drops the oldest upload of the backlog to make room
+	DropOldest = "oldest"
+
+	// DropNone blocks until the upload fits into the backlog, which slows
+	// down the build instead of dropping uploads
+	DropNone = "none"
+)
+
+const initialUploadBackoff = 500 * time.Millisecond
+
+// ErrSkipped is returned by uploads that are skipped instead of attempted,
+// e.g. because the remote storage is disabled, they are not counted as failed
+var ErrSkipped = errors.New("upload skipped")
+
+// QueueOptions configures the upload queue, zero values use the defaults
+type QueueOptions struct {
+	Concurrency int
+	Backlog     int
+	DropPolicy  string
+
+	// Retries of uploads failing with a transient error, a negative number
+	// disables retrying
+	Retries   int
+	Transient func(err error) bool
+}
+
+// Job is the upload of an action
+type Job struct {
+	ActionId string
+	Upload   func(ctx context.Context) error
+
+	// Done is called with the result of the upload, unless the upload was
+	// dropped or cancelled
+	Done func(err error)
+}
+
+// UploadQueue runs uploads in the background using a limited number of
+// workers. The uploads use the context of the queue, which is independent
+// from the requests and only cancelled when closing the queue times out.
+type UploadQueue struct {
+	jobs       chan Job
+	dropPolicy string
+	retries    int
+	transient  func(err error) bool
+
+	// closed is set under the write lock when closing the jobs channel,
+	// senders hold the read lock, so that they never send on a closed channel.
+	// Senders waiting for room in the backlog give up once closing is closed.
+	mutex     sync.RWMutex
+	closed    bool
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	log *log.Logger
+
+	succeeded atomic.Int64
+	failed    atomic.Int64
+	skipped   atomic.Int64
+}
+
+func validateDropPolicy(dropPolicy string) error {
+	switch dropPolicy {
+	case "", DropNewest, DropOldest, DropNone:
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported drop policy %q, supported are %q, %q, and %q", dropPolicy, DropNewest, DropOldest, DropNone)
+	}
+}
+
+func NewUploadQueue(options QueueOptions, logger *log.Logger) (*UploadQueue, error) {
+	if err := validateDropPolicy(options.DropPolicy); err != nil {
+		return nil, err
+	}
+
+	if options.Concurrency <= 0 {
+		options.Concurrency = DefaultUploadConcurrency
+	}
+
+	if options.Backlog <= 0 {
+		options.Backlog = DefaultUploadBacklog
+	}
+
+	if options.Retries == 0 {
+		options.Retries = DefaultUploadRetries
+	}
+
+	if options.Transient == nil {
+		options.Transient = func(error) bool { return false }
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	q := &UploadQueue{
+		jobs:       make(chan Job, options.Backlog),
+		closing:    make(chan struct{}),
+		dropPolicy: options.DropPolicy,
+		retries:    options.Retries,
+		transient:  options.Transient,
+		ctx:        ctx,
+		cancel:     cancel,
+		log:        logger,
+	}
+
+	for range options.Concurrency {
+		q.workers.Add(1)
+		go q.work()
+	}
+
+	return q, nil
+}
+
+// Enqueue adds the upload to the backlog, or drops an upload according to
+// the drop policy if the backlog is full. Uploads are dropped as well once
+// the queue is closed.
+func (q *UploadQueue) Enqueue(job Job) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	if q.closed {
+		q.skipped.Add(1)
+		q.log.Printf("upload queue is closed, dropped upload of action %s", job.ActionId)
+		return
+	}
+
+	switch q.dropPolicy {
+	case DropNone:
+		select {
+		case q.jobs <- job:
+		case <-q.closing:
+			q.skipped.Add(1)
+		}
+
+	case DropOldest:
+		for {
+			select {
+			case q.jobs <- job:
+				return
+
+			default:
+			}
+
+			select {
+			case dropped := <-q.jobs:
+				q.skipped.Add(1)
+				q.log.Printf("upload backlog is full, dropped upload of action %s", dropped.ActionId)
+
+			default:
+			}
+		}
+
+	default:
+		select {
+		case q.jobs <- job:
+
+		default:
+			q.skipped.Add(1)
+			q.log.Printf("upload backlog is full, dropped upload of action %s", job.ActionId)
+		}
+	}
+}
+
+// TryEnqueue adds the upload to the backlog, unless the backlog is full or
+// the queue is closed
+func (q *UploadQueue) TryEnqueue(job Job) bool {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	if q.closed {
+		return false
+	}
+
+	select {
+	case q.jobs <- job:
+		return true
+
+	default:
+		return false
+	}
+}
+
+func (q *UploadQueue) work() {
+	defer q.workers.Done()
+
+	for job := range q.jobs {
+		if q.ctx.Err() != nil {
+			q.skipped.Add(1)
+			continue
+		}
+
+		err := q.run(job)
+		switch {
+		case q.ctx.Err() != nil, errors.Is(err, ErrSkipped):
+			q.skipped.Add(1)
+			continue
+
+		case err != nil:
+			q.failed.Add(1)
+			q.log.Printf("failed to upload action %s: %v", job.ActionId, err)
+
+		default:
+			q.succeeded.Add(1)
+		}
+
+		if job.Done != nil {
+			job.Done(err)
+		}
+	}
+}
+
+// run runs the upload, and retries it in case of a transient error
+func (q *UploadQueue) run(job Job) error {
+	return Retry(q.ctx, q.retries, initialUploadBackoff, q.transient, func() error {
+		return job.Upload(q.ctx)
+	})
+}
+
+// Close waits for the pending uploads, if the context is done before, the
+// pending uploads are cancelled
+func (q *UploadQueue) Close(ctx context.Context) error {
+	q.closeOnce.Do(func() {
+		close(q.closing)
+
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+
+		q.closed = true
+		close(q.jobs)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+
+	case <-ctx.Done():
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Summary returns the number of succeeded, failed, and skipped uploads, or
+// an empty string if there were none
+func (q *UploadQueue) Summary() string {
+	if q.succeeded.Load()+q.failed.Load()+q.skipped.Load() == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d succeeded, %d failed, %d skipped", q.succeeded.Load(), q.failed.Load(), q.skipped.Load())
+}
+
+// Retry runs the function and retries it with exponential backoff as long
+// as it fails with a transient error, up to the given number of retries
+func Retry(ctx context.Context, retries int, backoff time.Duration, transient func(err error) bool, f func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= retries || !transient(err) {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
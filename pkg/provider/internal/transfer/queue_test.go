@@ -0,0 +1,379 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient")
+
+func isTransient(err error) bool {
+	return errors.Is(err, errTransient)
+}
+
+func newTestQueue(t *testing.T, options QueueOptions) *UploadQueue {
+	t.Helper()
+
+	options.Transient = isTransient
+
+	q, err := NewUploadQueue(options, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return q
+}
+
+func TestUploadQueueRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		retries  int
+		errors   []error
+		attempts int64
+		summary  string
+	}{
+		{
+			name:     "success",
+			errors:   []error{nil},
+			attempts: 1,
+			summary:  "1 succeeded, 0 failed, 0 skipped",
+		},
+		{
+			name:     "transient error",
+			retries:  2,
+			errors:   []error{errTransient, errTransient, nil},
+			attempts: 3,
+			summary:  "1 succeeded, 0 failed, 0 skipped",
+		},
+		{
+			name:     "transient error exceeding the retries",
+			retries:  1,
+			errors:   []error{errTransient, errTransient, nil},
+			attempts: 2,
+			summary:  "0 succeeded, 1 failed, 0 skipped",
+		},
+		{
+			name:     "retries disabled",
+			retries:  -1,
+			errors:   []error{errTransient, nil},
+			attempts: 1,
+			summary:  "0 succeeded, 1 failed, 0 skipped",
+		},
+		{
+			name:     "permanent error",
+			retries:  2,
+			errors:   []error{errors.New("permanent"), nil},
+			attempts: 1,
+			summary:  "0 succeeded, 1 failed, 0 skipped",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t, QueueOptions{Retries: tt.retries})
+
+			var attempts atomic.Int64
+			q.Enqueue(Job{ActionId: "action", Upload: func(context.Context) error {
+				return tt.errors[attempts.Add(1)-1]
+			}})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			if err := q.Close(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if attempts.Load() != tt.attempts {
+				t.Errorf("got %d attempts, want %d", attempts.Load(), tt.attempts)
+			}
+
+			if summary := q.Summary(); summary != tt.summary {
+				t.Errorf("got summary %q, want %q", summary, tt.summary)
+			}
+		})
+	}
+}
+
+func TestUploadQueueDropPolicy(t *testing.T) {
+	tests := []struct {
+		dropPolicy string
+		uploaded   []string
+	}{
+		{dropPolicy: DropNewest, uploaded: []string{"running", "first"}},
+		{dropPolicy: DropOldest, uploaded: []string{"running", "second"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dropPolicy, func(t *testing.T) {
+			q := newTestQueue(t, QueueOptions{Concurrency: 1, Backlog: 1, DropPolicy: tt.dropPolicy})
+
+			started, release := make(chan struct{}), make(chan struct{})
+			uploaded := make(chan string, 3)
+
+			q.Enqueue(Job{ActionId: "running", Upload: func(context.Context) error {
+				close(started)
+				<-release
+				uploaded <- "running"
+				return nil
+			}})
+			<-started
+
+			// The worker is busy, so that only one of the uploads fits into
+			// the backlog
+			for _, actionId := range []string{"first", "second"} {
+				q.Enqueue(Job{ActionId: actionId, Upload: func(context.Context) error {
+					uploaded <- actionId
+					return nil
+				}})
+			}
+
+			close(release)
+			if err := q.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			close(uploaded)
+
+			var got []string
+			for actionId := range uploaded {
+				got = append(got, actionId)
+			}
+
+			if len(got) != len(tt.uploaded) || got[0] != tt.uploaded[0] || got[1] != tt.uploaded[1] {
+				t.Errorf("got uploads %v, want %v", got, tt.uploaded)
+			}
+
+			if summary := q.Summary(); summary != "2 succeeded, 0 failed, 1 skipped" {
+				t.Errorf("got summary %q", summary)
+			}
+		})
+	}
+}
+
+func TestUploadQueueWaitsForBacklog(t *testing.T) {
+	q := newTestQueue(t, QueueOptions{Concurrency: 1, Backlog: 1, DropPolicy: DropNone})
+
+	var uploads atomic.Int64
+	upload := func(context.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		uploads.Add(1)
+		return nil
+	}
+
+	for range 5 {
+		q.Enqueue(Job{ActionId: "action", Upload: upload})
+	}
+
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if uploads.Load() != 5 {
+		t.Errorf("got %d uploads, want all 5", uploads.Load())
+	}
+}
+
+func TestUploadQueueConcurrency(t *testing.T) {
+	q := newTestQueue(t, QueueOptions{Concurrency: 2})
+
+	var running, maxRunning atomic.Int64
+	for range 10 {
+		q.Enqueue(Job{ActionId: "action", Upload: func(context.Context) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+
+			for m := maxRunning.Load(); n > m && !maxRunning.CompareAndSwap(m, n); m = maxRunning.Load() {
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}})
+	}
+
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if maxRunning.Load() > 2 {
+		t.Errorf("got %d concurrent uploads, want at most 2", maxRunning.Load())
+	}
+}
+
+func TestUploadQueueCloseTimeout(t *testing.T) {
+	q := newTestQueue(t, QueueOptions{Concurrency: 1})
+
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	q.Enqueue(Job{ActionId: "action", Upload: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	}})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := q.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	select {
+	case <-cancelled:
+	default:
+		t.Error("expected running upload to be cancelled")
+	}
+}
+
+func TestNewUploadQueue(t *testing.T) {
+	if _, err := NewUploadQueue(QueueOptions{DropPolicy: "random"}, log.New(io.Discard, "", 0)); err == nil {
+		t.Error("expected unsupported drop policy to fail")
+	}
+
+	q := newTestQueue(t, QueueOptions{})
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if summary := q.Summary(); summary != "" {
+		t.Errorf("got summary %q, want none", summary)
+	}
+}
+
+func TestUploadQueueDone(t *testing.T) {
+	q := newTestQueue(t, QueueOptions{Concurrency: 1, Backlog: 1, Retries: -1})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	results := make(chan error, 2)
+
+	q.Enqueue(Job{
+		ActionId: "running",
+		Upload: func(context.Context) error {
+			close(started)
+			<-release
+			return errTransient
+		},
+		Done: func(err error) { results <- err },
+	})
+	<-started
+
+	if !q.TryEnqueue(Job{ActionId: "waiting", Upload: func(context.Context) error { return nil }, Done: func(err error) { results <- err }}) {
+		t.Fatal("expected upload to fit into the backlog")
+	}
+
+	if q.TryEnqueue(Job{ActionId: "dropped", Upload: func(context.Context) error { return nil }}) {
+		t.Error("expected upload not to fit into the full backlog")
+	}
+
+	close(release)
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-results; !errors.Is(err, errTransient) {
+		t.Errorf("got %v, want %v", err, errTransient)
+	}
+
+	if err := <-results; err != nil {
+		t.Errorf("got %v, want success", err)
+	}
+}
+
+func TestUploadQueueEnqueueAfterClose(t *testing.T) {
+	for _, dropPolicy := range []string{DropNewest, DropOldest, DropNone} {
+		t.Run(dropPolicy, func(t *testing.T) {
+			q := newTestQueue(t, QueueOptions{DropPolicy: dropPolicy})
+			if err := q.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			var uploaded atomic.Bool
+			job := Job{ActionId: "action", Upload: func(context.Context) error {
+				uploaded.Store(true)
+				return nil
+			}}
+
+			q.Enqueue(job)
+			if q.TryEnqueue(job) {
+				t.Error("expected upload not to be added to a closed queue")
+			}
+
+			if err := q.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if uploaded.Load() {
+				t.Error("expected upload not to run after the queue was closed")
+			}
+
+			if summary := q.Summary(); summary != "0 succeeded, 0 failed, 1 skipped" {
+				t.Errorf("got summary %q", summary)
+			}
+		})
+	}
+}
+
+func TestUploadQueueCloseStopsWaitingForBacklog(t *testing.T) {
+	q := newTestQueue(t, QueueOptions{Concurrency: 1, Backlog: 1, DropPolicy: DropNone})
+
+	release := make(chan struct{})
+	upload := func(ctx context.Context) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+
+		return nil
+	}
+
+	// The first upload occupies the only worker, the second one the backlog
+	q.Enqueue(Job{ActionId: "running", Upload: upload})
+	for !q.TryEnqueue(Job{ActionId: "waiting", Upload: upload}) {
+		time.Sleep(time.Millisecond)
+	}
+
+	enqueued := make(chan struct{})
+	go func() {
+		defer close(enqueued)
+		q.Enqueue(Job{ActionId: "blocked", Upload: upload})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := q.Close(ctx); err == nil {
+		t.Error("expected close to time out while uploads are running")
+	}
+	close(release)
+
+	select {
+	case <-enqueued:
+	case <-time.After(time.Second):
+		t.Fatal("expected blocked upload to be dropped when closing")
+	}
+}
@@ -21,33 +21,96 @@
 package local
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/homeport/go-cache-prog/pkg/cache"
 )
 
+// tmpSuffix is used for files that are still being written, they are
+// renamed to their final name only once they are complete
+const tmpSuffix = ".tmp"
+
+// staleTmpAge is the age after which temporary files are considered to be
+// left-overs of a process that was terminated while writing
+const staleTmpAge = time.Hour
+
 type provider struct {
 	cacheDir string
+	sync     bool
+	verify   bool
+
+	maxSize int64
+	maxAge  time.Duration
+
+	log      *log.Logger
+	repaired atomic.Int64
 }
 
 var _ cache.Provider = &provider{}
+var _ cache.ObjectLookup = &provider{}
 
 func NewProvider(cacheDir string) (*provider, error) {
 	cacheDir = filepath.Clean(cacheDir)
 
 	for _, name := range []string{"action", "object"} {
-		if err := os.MkdirAll(filepath.Join(cacheDir, name), os.FileMode(0755)); err != nil {
+		dir := filepath.Join(cacheDir, name)
+		if err := os.MkdirAll(dir, os.FileMode(0755)); err != nil {
 			return nil, err
 		}
+
+		removeStaleTmpFiles(dir)
 	}
 
-	return &provider{cacheDir: cacheDir}, nil
+	return &provider{
+		cacheDir: cacheDir,
+		log:      log.New(io.Discard, "", log.LstdFlags),
+	}, nil
+}
+
+// WithLogger configures the logger to be used to report repairs and other
+// noteworthy events of the cache directory
+func (p *provider) WithLogger(logger *log.Logger) *provider {
+	p.log = logger
+	return p
+}
+
+// WithSync configures whether files are synced to stable storage before
+// they are renamed into place, trading write performance for durability
+// in case of a system crash
+func (p *provider) WithSync(sync bool) *provider {
+	p.sync = sync
+	return p
+}
+
+// WithVerify configures whether the content of put requests is verified
+// against the object id (OutputID), which is the SHA-256 sum of the content
+func (p *provider) WithVerify(verify bool) *provider {
+	p.verify = verify
+	return p
+}
+
+// WithMaxSize configures the maximum size in bytes of all objects in the
+// cache directory, a value of zero disables the size limit
+func (p *provider) WithMaxSize(maxSize int64) *provider {
+	p.maxSize = maxSize
+	return p
+}
+
+// WithMaxAge configures the time after which action entries that were not
+// used are removed from the cache directory, a value of zero disables it
+func (p *provider) WithMaxAge(maxAge time.Duration) *provider {
+	p.maxAge = maxAge
+	return p
 }
 
 func (p *provider) actionPath(actionId string) string {
@@ -80,70 +143,229 @@ func (p *provider) Get(actionId string) (string, string, error) {
 		return "", "", err
 	}
 
-	var parts = strings.SplitN(string(data), ":", 2)
-	if len(parts) != 2 {
-		// TODO: delete invalid action entry
-		return notFound()
-	}
-
-	objectId := parts[0]
-
-	size, err := strconv.ParseInt(parts[1], 10, 64)
-	if err != nil {
-		// TODO: delete invalid action entry
+	objectId, size, ok := ParseActionEntry(data)
+	if !ok {
+		p.repair(actionId, "malformed action entry %q", data)
 		return notFound()
 	}
 
 	diskpath, err := filepath.Abs(p.objPath(objectId))
 	if err != nil {
-		// TODO: delete invalid action entry
+		p.repair(actionId, "invalid object path: %v", err)
 		return notFound()
 	}
 
 	fi, err := os.Stat(diskpath)
-	if err != nil {
-		// TODO: delete invalid action entry
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		p.repair(actionId, "object %s does not exist", objectId)
 		return notFound()
+
+	case err != nil:
+		return "", "", err
 	}
 
 	if fi.Size() != size {
-		// TODO: delete invalid action entry
+		p.repair(actionId, "object %s has size %d, but expected %d", objectId, fi.Size(), size)
 		return notFound()
 	}
 
+	p.touch(p.actionPath(actionId))
+
 	return objectId, diskpath, nil
 }
 
-func (p *provider) Put(actionId string, objectId string, body io.Reader) (string, error) {
+func (p *provider) Object(objectId string) (string, error) {
+	if _, err := hex.DecodeString(objectId); err != nil || len(objectId) == 0 {
+		return "", fmt.Errorf("invalid object id %q", objectId)
+	}
+
 	diskpath, err := filepath.Abs(p.objPath(objectId))
 	if err != nil {
 		return "", err
 	}
 
-	// TODO Add check whether file already exists?
+	if _, err := os.Stat(diskpath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return diskpath, nil
+}
 
-	file, err := os.Create(diskpath) // #nosec G304 - provider takes care of filepath clean call
+func (p *provider) Put(actionId string, objectId string, body io.Reader) (string, error) {
+	diskpath, err := filepath.Abs(p.objPath(objectId))
 	if err != nil {
 		return "", err
 	}
-	defer func() { _ = file.Close() }()
 
-	size, err := io.Copy(file, body)
+	// Write object before action entry, so that an action entry never
+	// references an object that does not exist (yet)
+	if p.verify {
+		body = cache.NewVerifyingReader(body, objectId)
+	}
+
+	size, err := p.writeFile(diskpath, body)
 	if err != nil {
 		return "", err
 	}
 
-	if err := os.WriteFile(p.actionPath(actionId), fmt.Appendf(nil, "%s:%d", objectId, size), os.FileMode(0644)); err != nil {
+	if _, err := p.writeFile(p.actionPath(actionId), strings.NewReader(FormatActionEntry(objectId, size))); err != nil {
 		return "", err
 	}
 
 	return diskpath, nil
 }
 
-func (p *provider) Close() error {
+// writeFile writes the content of the reader into a temporary file next to
+// the target path and renames it into place once it is complete, so that
+// readers (also in other processes) never observe partially written files
+func (p *provider) writeFile(path string, r io.Reader) (int64, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*"+tmpSuffix)
+	if err != nil {
+		return 0, err
+	}
+
+	var renamed bool
+	defer func() {
+		if !renamed {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}()
+
+	size, err := io.Copy(file, r)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := file.Chmod(os.FileMode(0644)); err != nil {
+		return 0, err
+	}
+
+	if p.sync {
+		if err := file.Sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return 0, err
+	}
+
+	renamed = true
+
+	if p.sync {
+		if err := syncDir(filepath.Dir(path)); err != nil {
+			return 0, err
+		}
+	}
+
+	return size, nil
+}
+
+// remove deletes the action entry, the object it references is left for
+// the trim pass, since it might be referenced by other action entries
+func (p *provider) remove(actionId string) error {
+	if err := os.Remove(p.actionPath(actionId)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
 	return nil
 }
 
+// repair removes an invalid action entry, so that it is not read over and
+// over again, and instead is replaced by the next put of the action
+func (p *provider) repair(actionId string, format string, args ...any) {
+	reason := fmt.Sprintf(format, args...)
+
+	if err := p.remove(actionId); err != nil {
+		p.log.Printf("failed to remove invalid action entry %s (%s): %v", actionId, reason, err)
+		return
+	}
+
+	p.repaired.Add(1)
+	p.log.Printf("removed invalid action entry %s: %s", actionId, reason)
+}
+
+func (p *provider) Close() error {
+	if repaired := p.repaired.Load(); repaired > 0 {
+		p.log.Printf("repaired %d invalid action entries in %s", repaired, p.cacheDir)
+	}
+
+	return p.Trim()
+}
+
+// MaxActionEntrySize limits how much is read of an action entry, which only
+// consists of the object id and the size
+const MaxActionEntrySize = 1024
+
+// FormatActionEntry returns the action entry referencing the object, the
+// same format is used by remote caches that store action entries as is
+func FormatActionEntry(objectId string, size int64) string {
+	return fmt.Sprintf("%s:%d", objectId, size)
+}
+
+// ParseActionEntry returns the object id and size of an action entry,
+// surrounding whitespace, e.g. a trailing newline, is ignored
+func ParseActionEntry(data []byte) (string, int64, bool) {
+	var parts = strings.SplitN(strings.TrimSpace(string(data)), ":", 2)
+	if len(parts) != 2 {
+		return "", -1, false
+	}
+
+	if _, err := hex.DecodeString(parts[0]); err != nil || len(parts[0]) == 0 {
+		return "", -1, false
+	}
+
+	size, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || size < 0 {
+		return "", -1, false
+	}
+
+	return parts[0], size, true
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir) // #nosec G304 - provider takes care of filepath clean call
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+
+	return d.Sync()
+}
+
+// removeStaleTmpFiles removes temporary files that were left behind by a
+// process that was killed while writing, files that are younger than the
+// threshold might still be in use by a concurrently running process
+func removeStaleTmpFiles(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), tmpSuffix) {
+			continue
+		}
+
+		fi, err := entry.Info()
+		if err != nil || time.Since(fi.ModTime()) < staleTmpAge {
+			continue
+		}
+
+		_ = os.Remove(filepath.Join(dir, entry.Name()))
+	}
+}
+
 func notFound() (string, string, error) {
 	return "", "", nil
 }
@@ -0,0 +1,291 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package local
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/homeport/go-cache-prog/pkg/cache"
+)
+
+func newTestProvider(t *testing.T) *provider {
+	t.Helper()
+
+	p, err := NewProvider(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+// tmpFiles returns the temporary files left in the cache directory
+func tmpFiles(t *testing.T, p *provider) []string {
+	t.Helper()
+
+	var files []string
+	for _, name := range []string{"action", "object"} {
+		matches, err := filepath.Glob(filepath.Join(p.cacheDir, name, "*"+tmpSuffix))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files = append(files, matches...)
+	}
+
+	return files
+}
+
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestPutAndGet(t *testing.T) {
+	for _, sync := range []bool{false, true} {
+		p := newTestProvider(t).WithSync(sync)
+
+		diskpath, err := p.Put("0a", "0b", strings.NewReader("build output"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		objectId, path, err := p.Get("0a")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if objectId != "0b" || path != diskpath {
+			t.Errorf("got %q, %q, want %q, %q", objectId, path, "0b", diskpath)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != "build output" {
+			t.Errorf("got content %q", data)
+		}
+
+		if files := tmpFiles(t, p); len(files) != 0 {
+			t.Errorf("expected no temporary files, got %v", files)
+		}
+	}
+}
+
+func TestPutWithFailingBody(t *testing.T) {
+	p := newTestProvider(t)
+
+	if _, err := p.Put("0a", "0b", strings.NewReader("previous output")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The body of the second put fails halfway, the complete object of the
+	// first put must not be replaced by a partial one
+	if _, err := p.Put("0a", "0b", &failingReader{data: []byte("partial")}); err == nil {
+		t.Fatal("expected put to fail")
+	}
+
+	objectId, diskpath, err := p.Get("0a")
+	if err != nil || objectId != "0b" {
+		t.Fatalf("got %q, %v, want hit of previous put", objectId, err)
+	}
+
+	data, err := os.ReadFile(diskpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "previous output" {
+		t.Errorf("got content %q", data)
+	}
+
+	if files := tmpFiles(t, p); len(files) != 0 {
+		t.Errorf("expected no temporary files, got %v", files)
+	}
+}
+
+func TestPutWithFailingBodyWritesNoActionEntry(t *testing.T) {
+	p := newTestProvider(t)
+
+	if _, err := p.Put("0a", "0b", io.MultiReader(bytes.NewReader([]byte("partial")), &failingReader{})); err == nil {
+		t.Fatal("expected put to fail")
+	}
+
+	if _, err := os.Stat(p.actionPath("0a")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no action entry, got %v", err)
+	}
+
+	if _, err := os.Stat(p.objPath("0b")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no object, got %v", err)
+	}
+}
+
+func TestRemoveStaleTmpFiles(t *testing.T) {
+	cacheDir := t.TempDir()
+	dir := filepath.Join(cacheDir, "object")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := filepath.Join(dir, "0b.123"+tmpSuffix)
+	recent := filepath.Join(dir, "0c.456"+tmpSuffix)
+	for _, path := range []string{stale, recent} {
+		if err := os.WriteFile(path, []byte("partial"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := time.Now().Add(-2 * staleTmpAge)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewProvider(cacheDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(stale); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected stale temporary file to be removed, got %v", err)
+	}
+
+	// A recent temporary file might still be written by another process
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("expected recent temporary file to be kept, got %v", err)
+	}
+}
+
+func TestGetRepairsInvalidActionEntries(t *testing.T) {
+	tests := []struct {
+		name   string
+		entry  string
+		object string
+	}{
+		{name: "malformed entry", entry: "no separator"},
+		{name: "invalid size", entry: "0b:size"},
+		{name: "invalid object id", entry: "../../0b:7"},
+		{name: "missing object", entry: "0b:7"},
+		{name: "size mismatch", entry: "0b:7", object: "too long content"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			p := newTestProvider(t).WithLogger(log.New(&logs, "", 0))
+
+			if err := os.WriteFile(p.actionPath("0a"), []byte(tt.entry), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.object != "" {
+				if err := os.WriteFile(p.objPath("0b"), []byte(tt.object), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			objectId, diskpath, err := p.Get("0a")
+			if objectId != "" || diskpath != "" || err != nil {
+				t.Fatalf("got %q, %q, %v, want miss", objectId, diskpath, err)
+			}
+
+			if _, err := os.Stat(p.actionPath("0a")); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("expected invalid action entry to be removed, got %v", err)
+			}
+
+			if !strings.Contains(logs.String(), "removed invalid action entry 0a") {
+				t.Errorf("expected repair to be logged, got %q", logs.String())
+			}
+
+			if err := p.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(logs.String(), "repaired 1 invalid action entries") {
+				t.Errorf("expected repairs to be counted, got %q", logs.String())
+			}
+		})
+	}
+}
+
+func TestPutReplacesRepairedActionEntry(t *testing.T) {
+	p := newTestProvider(t)
+
+	if err := os.WriteFile(p.actionPath("0a"), []byte("malformed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if objectId, _, _ := p.Get("0a"); objectId != "" {
+		t.Fatalf("got %q, want miss", objectId)
+	}
+
+	if _, err := p.Put("0a", "0b", strings.NewReader("content")); err != nil {
+		t.Fatal(err)
+	}
+
+	if objectId, _, err := p.Get("0a"); objectId != "0b" || err != nil {
+		t.Errorf("got %q, %v, want hit", objectId, err)
+	}
+}
+
+func TestPutVerify(t *testing.T) {
+	content := []byte("build output")
+	sum := sha256.Sum256(content)
+	objectId := hex.EncodeToString(sum[:])
+
+	p := newTestProvider(t).WithVerify(true)
+
+	if _, err := p.Put("0a", objectId, bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The content of the second action does not match its object id
+	if _, err := p.Put("0b", strings.Repeat("0", 64), bytes.NewReader(content)); !errors.Is(err, cache.ErrOutputMismatch) {
+		t.Fatalf("got %v, want %v", err, cache.ErrOutputMismatch)
+	}
+
+	if objectId, _, _ := p.Get("0b"); objectId != "" {
+		t.Errorf("got %q, expected mismatching content not to be stored", objectId)
+	}
+
+	if files := tmpFiles(t, p); len(files) != 0 {
+		t.Errorf("expected no temporary files, got %v", files)
+	}
+}
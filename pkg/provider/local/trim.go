@@ -0,0 +1,234 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package local
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// accessInterval is the granularity of the access time tracking, action
+// entries that were used more recently are not touched again to avoid a
+// write for every single cache hit
+const accessInterval = time.Hour
+
+// trimInterval is the minimum time between two trim passes, since a trim
+// pass has to read all entries of the cache directory
+const trimInterval = time.Hour
+
+// trimGracePeriod protects objects that were just written by a concurrent
+// process, but are not yet referenced by an action entry
+const trimGracePeriod = 10 * time.Minute
+
+const trimFile = "trim.txt"
+
+type actionEntry struct {
+	path       string
+	objectId   string
+	accessTime time.Time
+}
+
+// touch updates the modification time of the action entry, which is used
+// to track when it was last accessed
+func (p *provider) touch(path string) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(fi.ModTime()) < accessInterval {
+		return
+	}
+
+	_ = os.Chtimes(path, now, now)
+}
+
+// Trim trims the cache directory if a maximum size or age is configured.
+// It runs when closing the provider, long running processes can call it
+// periodically, it does nothing if the last trim pass of any process using
+// the cache directory was less than the trim interval ago.
+func (p *provider) Trim() error {
+	if p.maxSize <= 0 && p.maxAge <= 0 {
+		return nil
+	}
+
+	return p.trim()
+}
+
+// trim removes action entries that were not used within the configured
+// maximum age, and then the least recently used action entries until the
+// objects in the cache directory fit into the configured maximum size.
+// Objects that are not referenced by any action entry anymore are removed.
+func (p *provider) trim() error {
+	now := time.Now()
+
+	trimPath := filepath.Join(p.cacheDir, trimFile)
+	if data, err := os.ReadFile(trimPath); err == nil { // #nosec G304 - provider takes care of filepath clean call
+		if last, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			if now.Sub(time.Unix(last, 0)) < trimInterval {
+				return nil
+			}
+		}
+	}
+
+	// Record the trim pass before actually trimming, so that concurrently
+	// running processes do not start a trim pass of their own
+	if _, err := p.writeFile(trimPath, strings.NewReader(fmt.Sprintf("%d", now.Unix()))); err != nil {
+		return err
+	}
+
+	actions, err := p.readActionEntries()
+	if err != nil {
+		return err
+	}
+
+	objects, err := os.ReadDir(filepath.Join(p.cacheDir, "object"))
+	if err != nil {
+		return err
+	}
+
+	var (
+		totalSize int64
+		sizes     = map[string]int64{}
+		refs      = map[string]int{}
+	)
+
+	for _, object := range objects {
+		if object.IsDir() || strings.HasSuffix(object.Name(), tmpSuffix) {
+			continue
+		}
+
+		fi, err := object.Info()
+		if err != nil {
+			continue
+		}
+
+		// Objects without an action entry that were just written might
+		// belong to a concurrent put, these are not considered at all
+		if now.Sub(fi.ModTime()) < trimGracePeriod {
+			continue
+		}
+
+		sizes[object.Name()] = fi.Size()
+		totalSize += fi.Size()
+	}
+
+	for _, action := range actions {
+		refs[action.objectId]++
+	}
+
+	// Objects without any action entry are removed in any case
+	for objectId, size := range sizes {
+		if refs[objectId] == 0 {
+			totalSize -= size
+		}
+	}
+
+	var remove = func(action actionEntry) error {
+		if err := os.Remove(action.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		refs[action.objectId]--
+		if refs[action.objectId] == 0 {
+			totalSize -= sizes[action.objectId]
+		}
+
+		return nil
+	}
+
+	// Least recently used action entries first
+	slices.SortFunc(actions, func(a, b actionEntry) int {
+		return a.accessTime.Compare(b.accessTime)
+	})
+
+	for _, action := range actions {
+		var expired = p.maxAge > 0 && now.Sub(action.accessTime) > p.maxAge
+		var tooBig = p.maxSize > 0 && totalSize > p.maxSize
+		if !expired && !tooBig {
+			break
+		}
+
+		if err := remove(action); err != nil {
+			return err
+		}
+	}
+
+	for objectId := range sizes {
+		if refs[objectId] > 0 {
+			continue
+		}
+
+		if err := os.Remove(p.objPath(objectId)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *provider) readActionEntries() ([]actionEntry, error) {
+	dir := filepath.Join(p.cacheDir, "action")
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []actionEntry
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), tmpSuffix) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(path) // #nosec G304 - provider takes care of filepath clean call
+		if err != nil {
+			continue
+		}
+
+		objectId, _, ok := ParseActionEntry(data)
+		if !ok {
+			continue
+		}
+
+		actions = append(actions, actionEntry{
+			path:       path,
+			objectId:   objectId,
+			accessTime: fi.ModTime(),
+		})
+	}
+
+	return actions, nil
+}
@@ -0,0 +1,205 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package local
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// putEntry stores an entry whose action entry was last used at the access
+// time, its object is older than the trim grace period
+func putEntry(t *testing.T, p *provider, actionId string, objectId string, content string, accessTime time.Time) {
+	t.Helper()
+
+	if _, err := p.Put(actionId, objectId, strings.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(p.actionPath(actionId), accessTime, accessTime); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-2 * trimGracePeriod)
+	if err := os.Chtimes(p.objPath(objectId), old, old); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(t *testing.T, path string) bool {
+	t.Helper()
+
+	_, err := os.Stat(path)
+	switch {
+	case err == nil:
+		return true
+
+	case errors.Is(err, os.ErrNotExist):
+		return false
+
+	default:
+		t.Fatal(err)
+		return false
+	}
+}
+
+func TestTrimMaxAge(t *testing.T) {
+	p := newTestProvider(t).WithMaxAge(24 * time.Hour)
+
+	now := time.Now()
+	putEntry(t, p, "0a", "1a", "old", now.Add(-48*time.Hour))
+	putEntry(t, p, "0b", "1b", "recent", now.Add(-2*time.Hour))
+
+	if err := p.Trim(); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists(t, p.actionPath("0a")) || exists(t, p.objPath("1a")) {
+		t.Error("expected expired entry to be removed")
+	}
+
+	if !exists(t, p.actionPath("0b")) || !exists(t, p.objPath("1b")) {
+		t.Error("expected recently used entry to be kept")
+	}
+}
+
+func TestTrimMaxSize(t *testing.T) {
+	p := newTestProvider(t).WithMaxSize(20)
+
+	now := time.Now()
+	putEntry(t, p, "0a", "1a", "0123456789", now.Add(-3*time.Hour))
+	putEntry(t, p, "0b", "1b", "0123456789", now.Add(-2*time.Hour))
+	putEntry(t, p, "0c", "1c", "0123456789", now.Add(-1*time.Hour))
+
+	// Objects referenced by another action entry are only removed with the
+	// last action entry referencing them
+	putEntry(t, p, "0d", "1c", "0123456789", now.Add(-4*time.Hour))
+
+	if err := p.Trim(); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists(t, p.actionPath("0a")) || exists(t, p.objPath("1a")) {
+		t.Error("expected least recently used entry to be removed")
+	}
+
+	if exists(t, p.actionPath("0d")) {
+		t.Error("expected least recently used action entry to be removed")
+	}
+
+	for _, path := range []string{p.actionPath("0b"), p.objPath("1b"), p.actionPath("0c"), p.objPath("1c")} {
+		if !exists(t, path) {
+			t.Errorf("expected %s to be kept", filepath.Base(path))
+		}
+	}
+}
+
+func TestTrimUnreferencedObjects(t *testing.T) {
+	p := newTestProvider(t).WithMaxAge(24 * time.Hour)
+
+	for _, objectId := range []string{"1a", "1b"} {
+		if err := os.WriteFile(p.objPath(objectId), []byte("orphan"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only objects older than the grace period are removed, recent ones
+	// might belong to a put that did not write its action entry yet
+	old := time.Now().Add(-2 * trimGracePeriod)
+	if err := os.Chtimes(p.objPath("1a"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Trim(); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists(t, p.objPath("1a")) {
+		t.Error("expected unreferenced object to be removed")
+	}
+
+	if !exists(t, p.objPath("1b")) {
+		t.Error("expected recently written object to be kept")
+	}
+}
+
+func TestTrimInterval(t *testing.T) {
+	p := newTestProvider(t).WithMaxAge(24 * time.Hour)
+
+	if err := p.Trim(); err != nil {
+		t.Fatal(err)
+	}
+
+	putEntry(t, p, "0a", "1a", "old", time.Now().Add(-48*time.Hour))
+
+	// Another trim pass within the trim interval does nothing, even if it
+	// is started by another process using the same cache directory
+	other, err := NewProvider(p.cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := other.WithMaxAge(24 * time.Hour).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists(t, p.actionPath("0a")) {
+		t.Error("expected no trim pass within the trim interval")
+	}
+}
+
+func TestTrimDisabled(t *testing.T) {
+	p := newTestProvider(t)
+
+	putEntry(t, p, "0a", "1a", "old", time.Now().Add(-365*24*time.Hour))
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists(t, p.actionPath("0a")) || exists(t, filepath.Join(p.cacheDir, trimFile)) {
+		t.Error("expected no trim pass without maximum size and age")
+	}
+}
+
+func TestGetTracksAccessTime(t *testing.T) {
+	p := newTestProvider(t)
+
+	used := time.Now().Add(-2 * accessInterval)
+	putEntry(t, p, "0a", "1a", "content", used)
+
+	if objectId, _, err := p.Get("0a"); err != nil || objectId != "1a" {
+		t.Fatalf("got %q, %v, want hit", objectId, err)
+	}
+
+	fi, err := os.Stat(p.actionPath("0a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !fi.ModTime().After(used) {
+		t.Error("expected access time of action entry to be updated")
+	}
+}
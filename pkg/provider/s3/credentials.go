@@ -0,0 +1,176 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package s3
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/IBM/ibm-cos-sdk-go/aws"
+	"github.com/IBM/ibm-cos-sdk-go/aws/client"
+	"github.com/IBM/ibm-cos-sdk-go/aws/credentials"
+	"github.com/IBM/ibm-cos-sdk-go/aws/credentials/endpointcreds"
+	"github.com/IBM/ibm-cos-sdk-go/aws/ec2metadata"
+	"github.com/IBM/ibm-cos-sdk-go/aws/session"
+)
+
+// containerCredentialsHost is the link-local address of the ECS container
+// credentials endpoint, used with AWS_CONTAINER_CREDENTIALS_RELATIVE_URI
+const containerCredentialsHost = "http://169.254.170.2"
+
+// credentialsExpiryWindow renews temporary credentials before they expire
+const credentialsExpiryWindow = 5 * time.Minute
+
+// credentialChain uses the configured static credentials first, and falls
+// back to the standard AWS environment variables, the shared credentials
+// file, ECS container credentials, and EC2 instance profile credentials
+func credentialChain(config S3, session *session.Session) *credentials.Credentials {
+	var providers []credentials.Provider
+
+	if config.AccessKeyID != "" || config.SecretAccessKey != "" {
+		providers = append(providers, &credentials.StaticProvider{Value: credentials.Value{
+			AccessKeyID:     config.AccessKeyID,
+			SecretAccessKey: config.SecretAccessKey,
+			SessionToken:    config.SessionToken,
+		}})
+	}
+
+	providers = append(providers,
+		&credentials.EnvProvider{},
+		&credentials.SharedCredentialsProvider{Profile: config.Profile},
+	)
+
+	if provider := containerProvider(session); provider != nil {
+		providers = append(providers, provider)
+	}
+
+	if provider := instanceProfileProvider(session); provider != nil {
+		providers = append(providers, provider)
+	}
+
+	return credentials.NewChainCredentials(providers)
+}
+
+// profileCredentials uses the profile of the shared credentials file only,
+// e.g. the read-only profile, so that neither static credentials nor the
+// AWS_* environment variables, which typically have write access, take
+// precedence
+func profileCredentials(profile string) *credentials.Credentials {
+	return credentials.NewCredentials(&credentials.SharedCredentialsProvider{Profile: profile})
+}
+
+// containerProvider returns the ECS container credentials provider, if the
+// container credentials endpoint is configured in the environment
+func containerProvider(session *session.Session) credentials.Provider {
+	var endpoint string
+	switch {
+	case os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "":
+		endpoint = containerCredentialsHost + os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")
+
+	case os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != "":
+		endpoint = os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+
+	default:
+		return nil
+	}
+
+	return endpointcreds.NewProviderClient(*session.Config, session.Handlers, endpoint, func(p *endpointcreds.Provider) {
+		p.ExpiryWindow = credentialsExpiryWindow
+		p.AuthorizationToken = os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	})
+}
+
+// instanceProfileProvider returns the EC2 instance profile credentials
+// provider, unless the instance metadata service is disabled. The session
+// does not pick up a custom metadata endpoint, so it is read here.
+func instanceProfileProvider(session client.ConfigProvider) credentials.Provider {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return nil
+	}
+
+	config := aws.NewConfig()
+	if endpoint := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"); endpoint != "" {
+		config = config.WithEndpoint(endpoint)
+	}
+
+	return &ec2RoleProvider{client: ec2metadata.New(session, config)}
+}
+
+// ec2RoleProvider retrieves the temporary credentials of the IAM role that
+// is attached to the EC2 instance from the instance metadata service
+type ec2RoleProvider struct {
+	credentials.Expiry
+	client *ec2metadata.EC2Metadata
+}
+
+const ec2RoleProviderName = "EC2RoleProvider"
+
+const ec2RoleCredentialsPath = "iam/security-credentials/"
+
+func (p *ec2RoleProvider) Retrieve() (credentials.Value, error) {
+	return p.RetrieveWithContext(aws.BackgroundContext())
+}
+
+func (p *ec2RoleProvider) RetrieveWithContext(ctx credentials.Context) (credentials.Value, error) {
+	roles, err := p.client.GetMetadataWithContext(ctx, ec2RoleCredentialsPath)
+	if err != nil {
+		return credentials.Value{ProviderName: ec2RoleProviderName}, fmt.Errorf("failed to look up instance role: %w", err)
+	}
+
+	role, _, _ := strings.Cut(strings.TrimSpace(roles), "\n")
+	if role == "" {
+		return credentials.Value{ProviderName: ec2RoleProviderName}, fmt.Errorf("no instance role found")
+	}
+
+	data, err := p.client.GetMetadataWithContext(ctx, ec2RoleCredentialsPath+role)
+	if err != nil {
+		return credentials.Value{ProviderName: ec2RoleProviderName}, fmt.Errorf("failed to get credentials of instance role %q: %w", role, err)
+	}
+
+	var result struct {
+		Code            string
+		Message         string
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}
+
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		return credentials.Value{ProviderName: ec2RoleProviderName}, fmt.Errorf("failed to parse credentials of instance role %q: %w", role, err)
+	}
+
+	if result.Code != "Success" {
+		return credentials.Value{ProviderName: ec2RoleProviderName}, fmt.Errorf("failed to get credentials of instance role %q: %s %s", role, result.Code, result.Message)
+	}
+
+	p.SetExpiration(result.Expiration, credentialsExpiryWindow)
+
+	return credentials.Value{
+		AccessKeyID:     result.AccessKeyID,
+		SecretAccessKey: result.SecretAccessKey,
+		SessionToken:    result.Token,
+		ProviderName:    ec2RoleProviderName,
+	}, nil
+}
@@ -0,0 +1,186 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package s3 provides a cache provider for generic S3 compatible storage,
+// e.g. AWS S3, MinIO, or Ceph. It uses the same remote layout and local
+// cache directory handling as the IBM Cloud Object Storage provider.
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/IBM/ibm-cos-sdk-go/aws"
+	"github.com/IBM/ibm-cos-sdk-go/aws/session"
+	awss3 "github.com/IBM/ibm-cos-sdk-go/service/s3"
+	"github.com/homeport/go-cache-prog/pkg/cache"
+	"github.com/homeport/go-cache-prog/pkg/provider/cos"
+	"github.com/homeport/go-cache-prog/pkg/provider/internal/transfer"
+)
+
+const DefaultTimeout = 5 * time.Second
+const DefaultMaxRetries = 2
+
+// Bucket validation modes used when the provider is created
+const (
+	BucketCheckHead = "head"
+	BucketCheckList = "list"
+	BucketCheckNone = "none"
+)
+
+// Provider is the S3 cache provider, it shares its implementation with the
+// IBM Cloud Object Storage provider
+type Provider interface {
+	cache.ContextProvider
+	SetLogOutput(w io.Writer)
+	Migrate(ctx context.Context, workers int) (int, error)
+	ReplayJournal() int
+	Warm(ctx context.Context, options cos.WarmOptions) (cos.WarmResult, error)
+}
+
+type Config struct {
+	S3 S3 `json:"s3"`
+	cos.Options
+}
+
+type S3 struct {
+	// Endpoint of the S3 compatible storage, leave empty for AWS S3
+	Endpoint string `json:"endpoint"`
+	Region   string `json:"region"`
+	Bucket   string `json:"bucket"`
+
+	// Static credentials, if not set the standard AWS credential chain is
+	// used, i.e. AWS_* environment variables and the shared credentials file
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	SessionToken    string `json:"session_token"`
+	Profile         string `json:"profile"`
+
+	// Credentials used instead in read-only mode, so that read-only jobs do
+	// not need write-capable credentials
+	ReadOnlyAccessKeyID     string `json:"read_only_access_key_id"`
+	ReadOnlySecretAccessKey string `json:"read_only_secret_access_key"`
+	ReadOnlyProfile         string `json:"read_only_profile"`
+
+	// PathStyle uses path-style (endpoint/bucket/key) instead of virtual
+	// host style (bucket.endpoint/key) addressing, required by most MinIO
+	// and Ceph installations
+	PathStyle bool `json:"path_style"`
+
+	// BucketCheck configures how the bucket is validated on start-up
+	BucketCheck string `json:"bucket_check"`
+
+	// Timeout of requests, requests with a body may take longer, as long as
+	// the body is transferred at least at the minimum transfer rate (bytes
+	// per second)
+	Timeout         time.Duration `json:"timeout"`
+	MinTransferRate int64         `json:"min_transfer_rate"`
+
+	// MaxRetries of the SDK, downloads and uploads are not retried by the
+	// SDK, but by the provider using the download and upload retries
+	MaxRetries int `json:"max_retries"`
+}
+
+func NewProvider(config Config) (Provider, error) {
+	if config.S3.Bucket == "" {
+		return nil, fmt.Errorf("bucket cannot be empty")
+	}
+
+	if config.S3.Timeout == 0 {
+		config.S3.Timeout = DefaultTimeout
+	}
+
+	if config.S3.MaxRetries == 0 {
+		config.S3.MaxRetries = DefaultMaxRetries
+	}
+
+	if config.S3.BucketCheck == "" {
+		config.S3.BucketCheck = BucketCheckHead
+	}
+
+	if config.ReadOnly && config.S3.ReadOnlyAccessKeyID != "" {
+		config.S3.AccessKeyID = config.S3.ReadOnlyAccessKeyID
+		config.S3.SecretAccessKey = config.S3.ReadOnlySecretAccessKey
+		config.S3.SessionToken = ""
+	}
+
+	if config.ReadOnly && config.S3.ReadOnlyAccessKeyID != "" && config.S3.ReadOnlyProfile != "" {
+		return nil, fmt.Errorf("read-only access key and read-only profile cannot be used together")
+	}
+
+	session, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := credentialChain(config.S3, session)
+	if config.ReadOnly && config.S3.ReadOnlyProfile != "" {
+		credentials = profileCredentials(config.S3.ReadOnlyProfile)
+	}
+
+	client := awss3.New(
+		session,
+		aws.NewConfig().
+			WithEndpoint(config.S3.Endpoint).
+			WithRegion(config.S3.Region).
+			WithCredentials(credentials).
+			WithLowerCaseHeaderMaps(true).
+			WithS3ForcePathStyle(config.S3.PathStyle).
+			WithHTTPClient(transfer.NewHTTPClient(config.S3.Timeout, config.S3.MinTransferRate)).
+			WithMaxRetries(config.S3.MaxRetries),
+	)
+
+	return cos.NewProviderWithBucketCheck(client, config.S3.Bucket, config.Options, func() error {
+		return checkBucket(client, config.S3)
+	})
+}
+
+func checkBucket(client *awss3.S3, config S3) error {
+	switch config.BucketCheck {
+	case BucketCheckNone:
+		return nil
+
+	case BucketCheckHead:
+		if _, err := client.HeadBucket(&awss3.HeadBucketInput{Bucket: &config.Bucket}); err != nil {
+			return fmt.Errorf("failed to access bucket %q: %w", config.Bucket, err)
+		}
+
+		return nil
+
+	case BucketCheckList:
+		listBucketResp, err := client.ListBuckets(&awss3.ListBucketsInput{})
+		if err != nil {
+			return err
+		}
+
+		for _, bucket := range listBucketResp.Buckets {
+			if bucket.Name != nil && config.Bucket == *bucket.Name {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("failed to find bucket %q", config.Bucket)
+
+	default:
+		return fmt.Errorf("unsupported bucket check %q, supported are %q, %q, and %q", config.BucketCheck, BucketCheckHead, BucketCheckList, BucketCheckNone)
+	}
+}
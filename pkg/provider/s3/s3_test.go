@@ -0,0 +1,348 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/ibm-cos-sdk-go/aws"
+	"github.com/IBM/ibm-cos-sdk-go/aws/credentials"
+	"github.com/IBM/ibm-cos-sdk-go/aws/session"
+	awss3 "github.com/IBM/ibm-cos-sdk-go/service/s3"
+)
+
+// bucketServer is a stub S3 endpoint that knows a single bucket
+func bucketServer(t *testing.T, bucket string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead && r.URL.Path == "/"+bucket:
+			w.WriteHeader(http.StatusOK)
+
+		case r.Method == http.MethodGet && r.URL.Path == "/":
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, `<ListAllMyBucketsResult><Buckets><Bucket><Name>other</Name></Bucket><Bucket><Name>%s</Name></Bucket></Buckets></ListAllMyBucketsResult>`, bucket)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckBucket(t *testing.T) {
+	server := bucketServer(t, "cache")
+
+	session, err := session.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := awss3.New(session, aws.NewConfig().
+		WithEndpoint(server.URL).
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("id", "secret", "")).
+		WithS3ForcePathStyle(true).
+		WithMaxRetries(0))
+
+	tests := []struct {
+		name    string
+		bucket  string
+		check   string
+		wantErr bool
+	}{
+		{name: "head existing", bucket: "cache", check: BucketCheckHead},
+		{name: "head missing", bucket: "missing", check: BucketCheckHead, wantErr: true},
+		{name: "list existing", bucket: "cache", check: BucketCheckList},
+		{name: "list missing", bucket: "missing", check: BucketCheckList, wantErr: true},
+		{name: "none", bucket: "missing", check: BucketCheckNone},
+		{name: "unsupported", bucket: "cache", check: "get", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBucket(client, S3{Bucket: tt.bucket, BucketCheck: tt.check})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkBucket() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewProviderBucketCheck(t *testing.T) {
+	isolateCredentials(t)
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		wantErr  bool
+	}{
+		{name: "unreachable endpoint", endpoint: unreachable.URL},
+		{name: "missing bucket", endpoint: bucketServer(t, "other").URL, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{S3: S3{
+				Endpoint:        tt.endpoint,
+				Region:          "us-east-1",
+				Bucket:          "cache",
+				AccessKeyID:     "id",
+				SecretAccessKey: "secret",
+				PathStyle:       true,
+				MaxRetries:      1,
+			}}
+			config.CacheDir = t.TempDir()
+
+			provider, err := NewProvider(config)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected an unreachable endpoint not to fail, got %v", err)
+			}
+
+			if err := provider.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// isolateCredentials clears all credential sources of the environment, so
+// that only the ones a test configures are used
+func isolateCredentials(t *testing.T) {
+	t.Helper()
+
+	for _, name := range []string{
+		"AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY",
+		"AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY",
+		"AWS_SESSION_TOKEN", "AWS_PROFILE",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN",
+		"AWS_EC2_METADATA_SERVICE_ENDPOINT",
+	} {
+		t.Setenv(name, "")
+	}
+
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+// credentialsResponse is the JSON document served by the container
+// credentials endpoint and the instance metadata service
+func credentialsResponse(accessKeyID string) string {
+	data, _ := json.Marshal(map[string]string{
+		"Code":            "Success",
+		"AccessKeyId":     accessKeyID,
+		"SecretAccessKey": "secret",
+		"Token":           "token",
+		"Expiration":      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+
+	return string(data)
+}
+
+func containerServer(t *testing.T, authorization string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/credentials" || r.Header.Get("Authorization") != authorization {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		fmt.Fprint(w, credentialsResponse("container"))
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+func metadataServer(t *testing.T, role string) *httptest.Server {
+	t.Helper()
+
+	const token = "metadata-token"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == "/latest/api/token" {
+			w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+			fmt.Fprint(w, token)
+			return
+		}
+
+		if r.Header.Get("X-aws-ec2-metadata-token") != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch strings.TrimPrefix(r.URL.Path, "/latest/meta-data/") {
+		case ec2RoleCredentialsPath:
+			fmt.Fprintln(w, role)
+
+		case ec2RoleCredentialsPath + role:
+			fmt.Fprint(w, credentialsResponse("instance"))
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCredentialChain(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       S3
+		setup        func(t *testing.T)
+		wantKeyID    string
+		wantProvider string
+		wantErr      bool
+	}{
+		{
+			name:   "static credentials take precedence",
+			config: S3{AccessKeyID: "static", SecretAccessKey: "secret"},
+			setup: func(t *testing.T) {
+				t.Setenv("AWS_ACCESS_KEY_ID", "env")
+				t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+			},
+			wantKeyID:    "static",
+			wantProvider: credentials.StaticProviderName,
+		},
+		{
+			name: "environment",
+			setup: func(t *testing.T) {
+				t.Setenv("AWS_ACCESS_KEY_ID", "env")
+				t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+			},
+			wantKeyID:    "env",
+			wantProvider: credentials.EnvProviderName,
+		},
+		{
+			name: "container",
+			setup: func(t *testing.T) {
+				server := containerServer(t, "auth-token")
+				t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL+"/credentials")
+				t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "auth-token")
+			},
+			wantKeyID:    "container",
+			wantProvider: "CredentialsEndpointProvider",
+		},
+		{
+			name: "instance profile",
+			setup: func(t *testing.T) {
+				server := metadataServer(t, "cache-role")
+				t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+				t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", server.URL)
+			},
+			wantKeyID:    "instance",
+			wantProvider: ec2RoleProviderName,
+		},
+		{
+			name:    "no credentials",
+			setup:   func(t *testing.T) {},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateCredentials(t)
+			tt.setup(t)
+
+			session, err := session.NewSession()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			value, err := credentialChain(tt.config, session).Get()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got credentials of %s", value.ProviderName)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value.AccessKeyID != tt.wantKeyID || value.ProviderName != tt.wantProvider {
+				t.Errorf("got key %q from %s, want key %q from %s", value.AccessKeyID, value.ProviderName, tt.wantKeyID, tt.wantProvider)
+			}
+		})
+	}
+}
+
+func TestReadOnlyProfile(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "env")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	content := "[default]\naws_access_key_id = default\naws_secret_access_key = secret\n\n" +
+		"[readonly]\naws_access_key_id = readonly\naws_secret_access_key = secret\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := profileCredentials("readonly").Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value.AccessKeyID != "readonly" || value.ProviderName != credentials.SharedCredsProviderName {
+		t.Errorf("got key %q from %s, want key %q from %s", value.AccessKeyID, value.ProviderName, "readonly", credentials.SharedCredsProviderName)
+	}
+
+	var config Config
+	config.ReadOnly = true
+	config.S3.ReadOnlyProfile = "readonly"
+	config.S3.ReadOnlyAccessKeyID = "static"
+	config.S3.ReadOnlySecretAccessKey = "secret"
+	if _, err := NewProvider(config); err == nil {
+		t.Error("expected an error for a read-only access key together with a read-only profile")
+	}
+}
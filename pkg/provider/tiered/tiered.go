@@ -0,0 +1,235 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package tiered provides a cache provider that composes other providers
+// into an ordered list of tiers, from the fastest to the slowest tier.
+package tiered
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/homeport/go-cache-prog/pkg/cache"
+	"github.com/homeport/go-cache-prog/pkg/provider/internal/transfer"
+)
+
+// Write modes of the tiered provider
+const (
+	// WriteThrough writes into all writable tiers before a put is completed
+	WriteThrough = "through"
+
+	// WriteBack writes into the first writable tier only before a put is
+	// completed, all other writable tiers are written in the background
+	WriteBack = "back"
+)
+
+// backgroundWriteTimeout limits how long a write into a slower tier in the
+// background may take, so that a hanging tier does not block the workers
+const backgroundWriteTimeout = 5 * time.Minute
+
+type Tier struct {
+	Provider cache.ContextProvider
+
+	// Write configures whether puts are written to this tier, including the
+	// back-fill of entries found in slower tiers
+	Write bool
+}
+
+type provider struct {
+	tiers     []Tier
+	writeMode string
+
+	// Writes into the slower tiers in write-back mode run in the background
+	// using a bounded queue, so that they do not pile up without limit
+	writes *transfer.UploadQueue
+
+	log *log.Logger
+}
+
+var _ cache.ContextProvider = &provider{}
+
+// NewProvider creates a provider that reads through the tiers in order, and
+// back-fills faster tiers when an entry is found in a slower tier. Every
+// tier has to return local disk paths, which is the case for all providers
+// that use a local cache directory.
+func NewProvider(tiers []Tier, writeMode string) (*provider, error) {
+	if len(tiers) == 0 {
+		return nil, fmt.Errorf("at least one tier is required")
+	}
+
+	switch writeMode {
+	case "":
+		writeMode = WriteThrough
+
+	case WriteThrough, WriteBack:
+
+	default:
+		return nil, fmt.Errorf("unsupported write mode %q, supported are %q and %q", writeMode, WriteThrough, WriteBack)
+	}
+
+	var writable bool
+	for _, tier := range tiers {
+		writable = writable || tier.Write
+	}
+
+	if !writable {
+		return nil, fmt.Errorf("at least one tier has to be writable")
+	}
+
+	logger := log.New(io.Discard, "", log.LstdFlags)
+
+	writes, err := transfer.NewUploadQueue(transfer.QueueOptions{}, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &provider{
+		tiers:     tiers,
+		writeMode: writeMode,
+		writes:    writes,
+		log:       logger,
+	}, nil
+}
+
+func (p *provider) WithLogOutput(w io.Writer) *provider {
+	p.log.SetOutput(w)
+	return p
+}
+
+func (p *provider) KnownCommands() []string {
+	return []string{"get", "put", "close"}
+}
+
+func (p *provider) Get(ctx context.Context, actionId string) (string, string, error) {
+	for i, tier := range p.tiers {
+		objectId, diskpath, err := tier.Provider.Get(ctx, actionId)
+		if err != nil {
+			p.log.Printf("failed to get %s from tier #%d: %v", actionId, i, err)
+			continue
+		}
+
+		if objectId == "" || diskpath == "" {
+			continue
+		}
+
+		// Back-fill the faster tiers, starting with the closest one, the
+		// disk path of the fastest tier is returned
+		for j := i - 1; j >= 0; j-- {
+			if !p.tiers[j].Write {
+				continue
+			}
+
+			backfilled, err := p.put(ctx, p.tiers[j], actionId, objectId, diskpath)
+			if err != nil {
+				p.log.Printf("failed to back-fill %s into tier #%d: %v", actionId, j, err)
+				continue
+			}
+
+			diskpath = backfilled
+		}
+
+		return objectId, diskpath, nil
+	}
+
+	return "", "", nil
+}
+
+func (p *provider) Put(ctx context.Context, actionId string, objectId string, body io.Reader) (string, error) {
+	var (
+		diskpath string
+		first    = true
+	)
+
+	for i, tier := range p.tiers {
+		if !tier.Write {
+			continue
+		}
+
+		// The body can only be read once, it goes into the first writable
+		// tier, all others are written from its disk path
+		if first {
+			path, err := tier.Provider.Put(ctx, actionId, objectId, body)
+			if err != nil {
+				return "", err
+			}
+
+			diskpath, first = path, false
+			continue
+		}
+
+		if p.writeMode == WriteBack {
+			// The write uses the context of the queue, which outlives the
+			// request, but not longer than the timeout
+			p.writes.Enqueue(transfer.Job{
+				ActionId: actionId,
+				Upload: func(ctx context.Context) error {
+					ctx, cancel := context.WithTimeout(ctx, backgroundWriteTimeout)
+					defer cancel()
+
+					if _, err := p.put(ctx, tier, actionId, objectId, diskpath); err != nil {
+						return fmt.Errorf("failed to write into tier #%d: %w", i, err)
+					}
+
+					return nil
+				},
+			})
+
+			continue
+		}
+
+		if _, err := p.put(ctx, tier, actionId, objectId, diskpath); err != nil {
+			p.log.Printf("failed to write %s into tier #%d: %v", actionId, i, err)
+		}
+	}
+
+	return diskpath, nil
+}
+
+func (p *provider) put(ctx context.Context, tier Tier, actionId string, objectId string, diskpath string) (string, error) {
+	file, err := os.Open(diskpath) // #nosec G304 - disk path is provided by a tier
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	return tier.Provider.Put(ctx, actionId, objectId, file)
+}
+
+// Close waits for the background writes, and closes all tiers, even if
+// waiting or closing one of them failed
+func (p *provider) Close(ctx context.Context) error {
+	errs := []error{p.writes.Close(ctx)}
+	if summary := p.writes.Summary(); summary != "" {
+		p.log.Printf("background writes: %s", summary)
+	}
+
+	for i, tier := range p.tiers {
+		if err := tier.Provider.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close tier #%d: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
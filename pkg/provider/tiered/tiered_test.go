@@ -0,0 +1,255 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tiered
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/homeport/go-cache-prog/pkg/cache"
+	"github.com/homeport/go-cache-prog/pkg/provider/local"
+)
+
+func newLocalTier(t *testing.T, write bool) Tier {
+	t.Helper()
+
+	provider, err := local.NewProvider(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Tier{Provider: cache.WithContext(provider), Write: write}
+}
+
+// failingProvider fails all requests
+type failingProvider struct{}
+
+func (failingProvider) KnownCommands() []string { return []string{"get", "put", "close"} }
+
+func (failingProvider) Get(context.Context, string) (string, string, error) {
+	return "", "", errors.New("unavailable")
+}
+
+func (failingProvider) Put(context.Context, string, string, io.Reader) (string, error) {
+	return "", errors.New("unavailable")
+}
+
+func (failingProvider) Close(context.Context) error { return nil }
+
+func get(t *testing.T, tier Tier, actionId string) (string, string) {
+	t.Helper()
+
+	objectId, diskpath, err := tier.Provider.Get(context.Background(), actionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return objectId, diskpath
+}
+
+func TestGetBackFillsFasterTiers(t *testing.T) {
+	fast, readOnly, slow := newLocalTier(t, true), newLocalTier(t, false), newLocalTier(t, true)
+
+	if _, err := slow.Provider.Put(context.Background(), "0a", "0b", strings.NewReader("content")); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewProvider([]Tier{fast, readOnly, slow}, WriteThrough)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objectId, diskpath, err := p.Get(context.Background(), "0a")
+	if err != nil || objectId != "0b" {
+		t.Fatalf("got %q, %v, want hit", objectId, err)
+	}
+
+	// The disk path of the fastest tier is returned
+	if fastObjectId, fastDiskpath := get(t, fast, "0a"); fastObjectId != "0b" || fastDiskpath != diskpath {
+		t.Errorf("expected entry to be back-filled into the fastest tier, got %q, %q", fastObjectId, fastDiskpath)
+	}
+
+	if objectId, _ := get(t, readOnly, "0a"); objectId != "" {
+		t.Error("expected entry not to be back-filled into a read-only tier")
+	}
+}
+
+func TestGetSkipsFailingTiers(t *testing.T) {
+	slow := newLocalTier(t, true)
+	if _, err := slow.Provider.Put(context.Background(), "0a", "0b", strings.NewReader("content")); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewProvider([]Tier{{Provider: failingProvider{}, Write: true}, slow}, WriteThrough)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if objectId, _, err := p.Get(context.Background(), "0a"); err != nil || objectId != "0b" {
+		t.Errorf("got %q, %v, want hit of the slower tier", objectId, err)
+	}
+
+	if objectId, _, err := p.Get(context.Background(), "0c"); err != nil || objectId != "" {
+		t.Errorf("got %q, %v, want miss", objectId, err)
+	}
+}
+
+func TestPut(t *testing.T) {
+	for _, writeMode := range []string{WriteThrough, WriteBack} {
+		t.Run(writeMode, func(t *testing.T) {
+			fast, readOnly, slow := newLocalTier(t, true), newLocalTier(t, false), newLocalTier(t, true)
+
+			p, err := NewProvider([]Tier{fast, readOnly, slow}, writeMode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			diskpath, err := p.Put(context.Background(), "0a", "0b", strings.NewReader("content"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, fastDiskpath := get(t, fast, "0a"); fastDiskpath != diskpath {
+				t.Errorf("expected disk path %q of the first tier, got %q", fastDiskpath, diskpath)
+			}
+
+			// Background writes are done once the provider is closed
+			if err := p.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			_, slowDiskpath := get(t, slow, "0a")
+			if slowDiskpath == "" {
+				t.Fatal("expected entry to be written into the slow tier")
+			}
+
+			if data, err := os.ReadFile(slowDiskpath); err != nil || string(data) != "content" {
+				t.Errorf("got %q, %v", data, err)
+			}
+
+			if objectId, _ := get(t, readOnly, "0a"); objectId != "" {
+				t.Error("expected entry not to be written into a read-only tier")
+			}
+		})
+	}
+}
+
+func TestPutIgnoresFailingSlowerTiers(t *testing.T) {
+	fast := newLocalTier(t, true)
+
+	p, err := NewProvider([]Tier{fast, {Provider: failingProvider{}, Write: true}}, WriteThrough)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Put(context.Background(), "0a", "0b", strings.NewReader("content")); err != nil {
+		t.Errorf("expected put to succeed with the first tier, got %v", err)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		tiers     []Tier
+		writeMode string
+	}{
+		{name: "no tiers"},
+		{name: "no writable tier", tiers: []Tier{{Provider: failingProvider{}}}},
+		{name: "unsupported write mode", tiers: []Tier{{Provider: failingProvider{}, Write: true}}, writeMode: "around"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewProvider(tt.tiers, tt.writeMode); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// cancellableTier fails puts with a cancelled context, and records whether
+// it was closed
+type cancellableTier struct {
+	cache.ContextProvider
+	closeErr error
+	closed   bool
+}
+
+func (c *cancellableTier) Put(ctx context.Context, actionId string, objectId string, body io.Reader) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return c.ContextProvider.Put(ctx, actionId, objectId, body)
+}
+
+func (c *cancellableTier) Close(ctx context.Context) error {
+	c.closed = true
+	return errors.Join(c.closeErr, c.ContextProvider.Close(ctx))
+}
+
+func TestWriteBackOutlivesRequest(t *testing.T) {
+	fast := newLocalTier(t, true)
+	slow := &cancellableTier{ContextProvider: newLocalTier(t, true).Provider}
+
+	p, err := NewProvider([]Tier{fast, {Provider: slow, Write: true}}, WriteBack)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request is done once the put returns, the background write
+	// continues anyway
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := p.Put(ctx, "0a", "0b", strings.NewReader("content")); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if objectId, _ := get(t, Tier{Provider: slow}, "0a"); objectId != "0b" {
+		t.Error("expected entry to be written into the slow tier")
+	}
+}
+
+func TestCloseClosesAllTiers(t *testing.T) {
+	failing := &cancellableTier{ContextProvider: newLocalTier(t, true).Provider, closeErr: errors.New("unavailable")}
+	other := &cancellableTier{ContextProvider: newLocalTier(t, true).Provider}
+
+	p, err := NewProvider([]Tier{{Provider: failing, Write: true}, {Provider: other, Write: true}}, WriteBack)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Close(context.Background()); err == nil || !strings.Contains(err.Error(), "tier #0: unavailable") {
+		t.Errorf("got %v, want error of the first tier", err)
+	}
+
+	if !failing.closed || !other.closed {
+		t.Error("expected all tiers to be closed")
+	}
+}
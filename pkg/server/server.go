@@ -0,0 +1,497 @@
+// Copyright © 2026 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package server provides an HTTP cache server, that stores action entries
+// under /ac/<actionId> and objects under /cas/<objectId> using a provider as
+// storage, so that it can be shared by clients using the HTTP provider.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/homeport/go-cache-prog/pkg/cache"
+	"github.com/homeport/go-cache-prog/pkg/provider/local"
+)
+
+// Access levels of tokens and anonymous requests
+const (
+	AccessNone      = "none"
+	AccessReadOnly  = "read-only"
+	AccessReadWrite = "read-write"
+)
+
+const DefaultQuotaPeriod = 24 * time.Hour
+const DefaultStagingTimeout = time.Hour
+
+type Config struct {
+	Tokens []Token `json:"tokens"`
+
+	// Anonymous is the access level of requests without a token
+	Anonymous string `json:"anonymous"`
+
+	// QuotaPeriod is the period after which the uploaded bytes of all
+	// tokens are reset
+	QuotaPeriod time.Duration `json:"quota_period"`
+
+	// StagingTimeout is the time after which a staged object is removed,
+	// if no action entry referencing it was stored in the meantime
+	StagingTimeout time.Duration `json:"staging_timeout"`
+}
+
+type Token struct {
+	Token  string `json:"token"`
+	Access string `json:"access"`
+
+	// Quota is the maximum number of bytes that can be uploaded using this
+	// token within the quota period, zero means no limit
+	Quota int64 `json:"quota"`
+}
+
+type Server struct {
+	provider cache.ContextProvider
+	config   Config
+
+	// Objects are staged until the action entry referencing them is stored
+	// in the provider, since providers only store objects as part of actions
+	stagingDir string
+	stopExpiry chan struct{}
+	expiring   sync.WaitGroup
+
+	quotaMutex sync.Mutex
+	quotaStart time.Time
+	quotaUsed  map[string]int64
+
+	log *log.Logger
+}
+
+func New(provider cache.ContextProvider, config Config) (*Server, error) {
+	if config.Anonymous == "" {
+		config.Anonymous = AccessNone
+	}
+
+	if config.QuotaPeriod <= 0 {
+		config.QuotaPeriod = DefaultQuotaPeriod
+	}
+
+	if config.StagingTimeout <= 0 {
+		config.StagingTimeout = DefaultStagingTimeout
+	}
+
+	for _, access := range append([]string{config.Anonymous}, accessOfTokens(config.Tokens)...) {
+		switch access {
+		case AccessNone, AccessReadOnly, AccessReadWrite:
+		default:
+			return nil, fmt.Errorf("unsupported access %q, supported are %q, %q, and %q", access, AccessNone, AccessReadOnly, AccessReadWrite)
+		}
+	}
+
+	stagingDir, err := os.MkdirTemp("", "go-cache-prog-serve-")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		provider:   provider,
+		config:     config,
+		stagingDir: stagingDir,
+		stopExpiry: make(chan struct{}),
+		quotaStart: time.Now(),
+		quotaUsed:  map[string]int64{},
+		log:        log.New(io.Discard, "", log.LstdFlags),
+	}
+
+	s.expiring.Add(1)
+	go s.expireStaged()
+
+	return s, nil
+}
+
+func accessOfTokens(tokens []Token) []string {
+	var result []string
+	for _, token := range tokens {
+		result = append(result, token.Access)
+	}
+
+	return result
+}
+
+func (s *Server) WithLogOutput(w io.Writer) *Server {
+	s.log.SetOutput(w)
+	return s
+}
+
+// Close closes the provider and removes all staged objects
+func (s *Server) Close(ctx context.Context) error {
+	close(s.stopExpiry)
+	s.expiring.Wait()
+
+	defer func() { _ = os.RemoveAll(s.stagingDir) }()
+	return s.provider.Close(ctx)
+}
+
+// expireStaged periodically removes staged objects that are older than the
+// staging timeout, e.g. because the client failed to store the action entry
+func (s *Server) expireStaged() {
+	defer s.expiring.Done()
+
+	ticker := time.NewTicker(s.config.StagingTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.removeExpiredStaged(time.Now().Add(-s.config.StagingTimeout))
+
+		case <-s.stopExpiry:
+			return
+		}
+	}
+}
+
+func (s *Server) removeExpiredStaged(before time.Time) {
+	entries, err := os.ReadDir(s.stagingDir)
+	if err != nil {
+		s.log.Printf("failed to read staging directory: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(s.stagingDir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.log.Printf("failed to remove expired staged object %s: %v", entry.Name(), err)
+		}
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kind, id, ok := parsePath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	token, access := s.authenticate(r)
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if access == AccessNone {
+			unauthorized(w)
+			return
+		}
+
+		switch kind {
+		case "ac":
+			s.getAction(w, r, id)
+
+		case "cas":
+			s.getObject(w, r, id)
+		}
+
+	case http.MethodPut:
+		switch access {
+		case AccessNone:
+			unauthorized(w)
+			return
+
+		case AccessReadOnly:
+			http.Error(w, "read-only access", http.StatusForbidden)
+			return
+		}
+
+		switch kind {
+		case "ac":
+			s.putAction(w, r, id)
+
+		case "cas":
+			s.putObject(w, r, token, id)
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func parsePath(path string) (string, string, bool) {
+	kind, id, found := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !found || (kind != "ac" && kind != "cas") {
+		return "", "", false
+	}
+
+	if _, err := hex.DecodeString(id); err != nil || len(id) == 0 {
+		return "", "", false
+	}
+
+	return kind, id, true
+}
+
+// authenticate returns the token configuration matching the bearer token of
+// the request and its access level, requests without a token are anonymous
+func (s *Server) authenticate(r *http.Request) (*Token, string) {
+	bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return nil, s.config.Anonymous
+	}
+
+	for i := range s.config.Tokens {
+		token := &s.config.Tokens[i]
+		if subtle.ConstantTimeCompare([]byte(token.Token), []byte(bearer)) == 1 {
+			return token, token.Access
+		}
+	}
+
+	return nil, AccessNone
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+func (s *Server) getAction(w http.ResponseWriter, r *http.Request, actionId string) {
+	objectId, diskpath, err := s.provider.Get(r.Context(), actionId)
+	if err != nil {
+		s.log.Printf("failed to get action %s: %v", actionId, err)
+		http.Error(w, "failed to get action", http.StatusInternalServerError)
+		return
+	}
+
+	if objectId == "" || diskpath == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	fi, err := os.Stat(diskpath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	entry := local.FormatActionEntry(objectId, fi.Size())
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Content-Length", strconv.Itoa(len(entry)))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodGet {
+		_, _ = io.WriteString(w, entry)
+	}
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, objectId string) {
+	diskpath, err := s.lookUpObject(objectId)
+	if err != nil {
+		s.log.Printf("failed to look up object %s: %v", objectId, err)
+		http.Error(w, "failed to look up object", http.StatusInternalServerError)
+		return
+	}
+
+	if diskpath == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeFile(w, r, diskpath)
+}
+
+// lookUpObject finds the object in the provider or the staged objects
+func (s *Server) lookUpObject(objectId string) (string, error) {
+	if lookup, ok := s.provider.(cache.ObjectLookup); ok {
+		diskpath, err := lookup.Object(objectId)
+		switch {
+		case errors.Is(err, errors.ErrUnsupported):
+
+		case err != nil:
+			return "", err
+
+		case diskpath != "":
+			return diskpath, nil
+		}
+	}
+
+	staged := filepath.Join(s.stagingDir, objectId)
+	if _, err := os.Stat(staged); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return staged, nil
+}
+
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, token *Token, objectId string) {
+	if r.ContentLength < 0 {
+		http.Error(w, "content length required", http.StatusLengthRequired)
+		return
+	}
+
+	refund, ok := s.reserveQuota(token, r.ContentLength)
+	if !ok {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	// Only objects that were staged count towards the quota
+	var staged bool
+	defer func() {
+		if !staged {
+			refund()
+		}
+	}()
+
+	file, err := os.CreateTemp(s.stagingDir, objectId+".*.tmp")
+	if err != nil {
+		s.log.Printf("failed to stage object %s: %v", objectId, err)
+		http.Error(w, "failed to stage object", http.StatusInternalServerError)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+
+	size, err := io.Copy(file, cache.NewVerifyingReader(http.MaxBytesReader(w, r.Body, r.ContentLength), objectId))
+	switch {
+	case errors.Is(err, cache.ErrOutputMismatch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+
+	case err != nil:
+		http.Error(w, "failed to read object", http.StatusBadRequest)
+		return
+
+	case size != r.ContentLength:
+		http.Error(w, "size mismatch", http.StatusBadRequest)
+		return
+	}
+
+	if err := file.Close(); err != nil {
+		http.Error(w, "failed to stage object", http.StatusInternalServerError)
+		return
+	}
+
+	if err := os.Rename(file.Name(), filepath.Join(s.stagingDir, objectId)); err != nil {
+		http.Error(w, "failed to stage object", http.StatusInternalServerError)
+		return
+	}
+
+	staged = true
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) putAction(w http.ResponseWriter, r *http.Request, actionId string) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, local.MaxActionEntrySize))
+	if err != nil {
+		http.Error(w, "failed to read action entry", http.StatusBadRequest)
+		return
+	}
+
+	objectId, size, ok := local.ParseActionEntry(data)
+	if !ok {
+		http.Error(w, "invalid action entry", http.StatusBadRequest)
+		return
+	}
+
+	diskpath, err := s.lookUpObject(objectId)
+	if err != nil || diskpath == "" {
+		http.Error(w, "object of action entry does not exist", http.StatusConflict)
+		return
+	}
+
+	file, err := os.Open(diskpath) // #nosec G304 - object id is validated to be hex encoded
+	if err != nil {
+		http.Error(w, "object of action entry does not exist", http.StatusConflict)
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	if fi, err := file.Stat(); err != nil || fi.Size() != size {
+		http.Error(w, "size mismatch", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.provider.Put(r.Context(), actionId, objectId, file); err != nil {
+		s.log.Printf("failed to put action %s: %v", actionId, err)
+		http.Error(w, "failed to store action", http.StatusInternalServerError)
+		return
+	}
+
+	// The staged object is no longer needed once the provider has it
+	if strings.HasPrefix(diskpath, s.stagingDir) {
+		if stored, err := s.lookUpObject(objectId); err == nil && stored != diskpath {
+			_ = os.Remove(diskpath)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// reserveQuota accounts the size to the quota of the token and reports
+// whether the token still has enough quota left. The returned function
+// gives the size back, if the upload fails, unless the quota was reset in
+// the meantime.
+func (s *Server) reserveQuota(token *Token, size int64) (func(), bool) {
+	if token == nil || token.Quota <= 0 {
+		return func() {}, true
+	}
+
+	s.quotaMutex.Lock()
+	defer s.quotaMutex.Unlock()
+
+	if time.Since(s.quotaStart) > s.config.QuotaPeriod {
+		s.quotaStart = time.Now()
+		s.quotaUsed = map[string]int64{}
+	}
+
+	if s.quotaUsed[token.Token]+size > token.Quota {
+		return func() {}, false
+	}
+
+	s.quotaUsed[token.Token] += size
+
+	start := s.quotaStart
+	return func() {
+		s.quotaMutex.Lock()
+		defer s.quotaMutex.Unlock()
+
+		if s.quotaStart.Equal(start) {
+			s.quotaUsed[token.Token] -= size
+		}
+	}, true
+}
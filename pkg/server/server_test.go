@@ -0,0 +1,266 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/homeport/go-cache-prog/pkg/cache"
+	"github.com/homeport/go-cache-prog/pkg/provider/local"
+)
+
+func newTestServer(t *testing.T, config Config) *httptest.Server {
+	t.Helper()
+
+	provider, err := local.NewProvider(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(cache.WithContext(provider), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(s)
+	t.Cleanup(func() {
+		server.Close()
+		_ = s.Close(context.Background())
+	})
+
+	return server
+}
+
+func objectIdOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func do(t *testing.T, method string, url string, token string, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.StatusCode, string(data)
+}
+
+func TestPutAndGet(t *testing.T) {
+	server := newTestServer(t, Config{Tokens: []Token{{Token: "writer", Access: AccessReadWrite}}})
+
+	content := "build output"
+	objectId := objectIdOf(content)
+	entry := local.FormatActionEntry(objectId, int64(len(content)))
+
+	if status, _ := do(t, http.MethodPut, server.URL+"/cas/"+objectId, "writer", content); status != http.StatusOK {
+		t.Fatalf("got status %d for object upload", status)
+	}
+
+	if status, _ := do(t, http.MethodPut, server.URL+"/ac/0a", "writer", entry); status != http.StatusOK {
+		t.Fatalf("got status %d for action entry upload", status)
+	}
+
+	if status, body := do(t, http.MethodGet, server.URL+"/ac/0a", "writer", ""); status != http.StatusOK || body != entry {
+		t.Errorf("got status %d and action entry %q, want %q", status, body, entry)
+	}
+
+	if status, body := do(t, http.MethodGet, server.URL+"/cas/"+objectId, "writer", ""); status != http.StatusOK || body != content {
+		t.Errorf("got status %d and object %q, want %q", status, body, content)
+	}
+
+	if status, _ := do(t, http.MethodGet, server.URL+"/ac/0b", "writer", ""); status != http.StatusNotFound {
+		t.Errorf("got status %d for missing action entry", status)
+	}
+}
+
+func TestAccess(t *testing.T) {
+	config := Config{
+		Anonymous: AccessReadOnly,
+		Tokens: []Token{
+			{Token: "writer", Access: AccessReadWrite},
+			{Token: "reader", Access: AccessReadOnly},
+		},
+	}
+
+	content := "build output"
+	objectId := objectIdOf(content)
+
+	tests := []struct {
+		name   string
+		config Config
+		method string
+		token  string
+		want   int
+	}{
+		{name: "anonymous read without anonymous access", config: Config{}, method: http.MethodGet, want: http.StatusUnauthorized},
+		{name: "anonymous read", config: config, method: http.MethodGet, want: http.StatusNotFound},
+		{name: "anonymous write", config: config, method: http.MethodPut, want: http.StatusForbidden},
+		{name: "unknown token", config: config, method: http.MethodGet, token: "unknown", want: http.StatusUnauthorized},
+		{name: "read-only token read", config: config, method: http.MethodGet, token: "reader", want: http.StatusNotFound},
+		{name: "read-only token write", config: config, method: http.MethodPut, token: "reader", want: http.StatusForbidden},
+		{name: "read-write token write", config: config, method: http.MethodPut, token: "writer", want: http.StatusOK},
+		{name: "unsupported method", config: config, method: http.MethodDelete, token: "writer", want: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, tt.config)
+
+			if status, _ := do(t, tt.method, server.URL+"/cas/"+objectId, tt.token, content); status != tt.want {
+				t.Errorf("got status %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuota(t *testing.T) {
+	server := newTestServer(t, Config{Tokens: []Token{
+		{Token: "limited", Access: AccessReadWrite, Quota: 20},
+		{Token: "unlimited", Access: AccessReadWrite},
+	}})
+
+	first, second := "0123456789", "9876543210"
+
+	for _, content := range []string{first, second} {
+		if status, _ := do(t, http.MethodPut, server.URL+"/cas/"+objectIdOf(content), "limited", content); status != http.StatusOK {
+			t.Fatalf("got status %d within quota", status)
+		}
+	}
+
+	if status, _ := do(t, http.MethodPut, server.URL+"/cas/"+objectIdOf("x"), "limited", "x"); status != http.StatusTooManyRequests {
+		t.Errorf("got status %d, want %d once the quota is used up", status, http.StatusTooManyRequests)
+	}
+
+	// The quota is tracked per token
+	if status, _ := do(t, http.MethodPut, server.URL+"/cas/"+objectIdOf("x"), "unlimited", "x"); status != http.StatusOK {
+		t.Errorf("got status %d for token without quota", status)
+	}
+}
+
+func TestInvalidUploads(t *testing.T) {
+	server := newTestServer(t, Config{Anonymous: AccessReadWrite})
+
+	content := "build output"
+	objectId := objectIdOf(content)
+
+	if status, _ := do(t, http.MethodPut, server.URL+"/cas/"+objectIdOf("other"), "", content); status != http.StatusBadRequest {
+		t.Errorf("got status %d for object not matching its id", status)
+	}
+
+	if status, _ := do(t, http.MethodPut, server.URL+"/ac/0a", "", "malformed"); status != http.StatusBadRequest {
+		t.Errorf("got status %d for malformed action entry", status)
+	}
+
+	if status, _ := do(t, http.MethodPut, server.URL+"/ac/0a", "", local.FormatActionEntry(objectId, int64(len(content)))); status != http.StatusConflict {
+		t.Errorf("got status %d for action entry of missing object", status)
+	}
+
+	if status, _ := do(t, http.MethodPut, server.URL+"/cas/"+objectId, "", content); status != http.StatusOK {
+		t.Fatalf("got status %d for object upload", status)
+	}
+
+	if status, _ := do(t, http.MethodPut, server.URL+"/ac/0a", "", local.FormatActionEntry(objectId, 1)); status != http.StatusBadRequest {
+		t.Errorf("got status %d for action entry with wrong size", status)
+	}
+
+	if status, _ := do(t, http.MethodGet, server.URL+"/ac/not-hex", "", ""); status != http.StatusNotFound {
+		t.Errorf("got status %d for invalid path", status)
+	}
+}
+
+func TestQuotaRefund(t *testing.T) {
+	server := newTestServer(t, Config{Tokens: []Token{{Token: "limited", Access: AccessReadWrite, Quota: 10}}})
+
+	content := "0123456789"
+
+	// Failed uploads do not count towards the quota
+	if status, _ := do(t, http.MethodPut, server.URL+"/cas/"+objectIdOf("other"), "limited", content); status != http.StatusBadRequest {
+		t.Fatalf("got status %d for object not matching its id", status)
+	}
+
+	if status, _ := do(t, http.MethodPut, server.URL+"/cas/"+objectIdOf(content), "limited", content); status != http.StatusOK {
+		t.Errorf("got status %d, want quota to be refunded", status)
+	}
+}
+
+func TestExpireStaged(t *testing.T) {
+	provider, err := local.NewProvider(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(cache.WithContext(provider), Config{Anonymous: AccessReadWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(s)
+	t.Cleanup(func() {
+		server.Close()
+		_ = s.Close(context.Background())
+	})
+
+	content := "build output"
+	objectId := objectIdOf(content)
+	entry := local.FormatActionEntry(objectId, int64(len(content)))
+
+	if status, _ := do(t, http.MethodPut, server.URL+"/cas/"+objectId, "", content); status != http.StatusOK {
+		t.Fatalf("got status %d for object upload", status)
+	}
+
+	// Objects staged after the expiry time are kept
+	s.removeExpiredStaged(time.Now().Add(-time.Hour))
+	if _, err := os.Stat(filepath.Join(s.stagingDir, objectId)); err != nil {
+		t.Fatalf("expected staged object to be kept, %v", err)
+	}
+
+	s.removeExpiredStaged(time.Now().Add(time.Hour))
+	if status, _ := do(t, http.MethodPut, server.URL+"/ac/0a", "", entry); status != http.StatusConflict {
+		t.Errorf("got status %d for action entry of expired object", status)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright © 2025 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package singleflight
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoSharesConcurrentCalls(t *testing.T) {
+	var (
+		group   Group[string]
+		calls   atomic.Int64
+		release = make(chan struct{})
+		started = make(chan struct{})
+	)
+
+	fn := func() (string, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+
+		<-release
+		return "result", nil
+	}
+
+	const callers = 5
+	var (
+		wg      sync.WaitGroup
+		ready   sync.WaitGroup
+		results = make([]string, callers)
+		shared  = make([]bool, callers)
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _, shared[0] = group.Do("key", fn)
+	}()
+
+	// The other calls start once the first call is in-flight
+	<-started
+	for i := 1; i < callers; i++ {
+		wg.Add(1)
+		ready.Add(1)
+		go func() {
+			defer wg.Done()
+			ready.Done()
+			results[i], _, shared[i] = group.Do("key", fn)
+		}()
+	}
+
+	ready.Wait()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("got %d calls, want 1", got)
+	}
+
+	for i, result := range results {
+		if result != "result" {
+			t.Errorf("call %d got %q", i, result)
+		}
+
+		if shared[i] != (i > 0) {
+			t.Errorf("call %d got shared %v", i, shared[i])
+		}
+	}
+}
+
+func TestDoSharesErrors(t *testing.T) {
+	var group Group[int]
+	failure := errors.New("failure")
+
+	if _, err, _ := group.Do("key", func() (int, error) { return 0, failure }); !errors.Is(err, failure) {
+		t.Errorf("got %v, want %v", err, failure)
+	}
+}
+
+func TestDoAfterCompletedCall(t *testing.T) {
+	var (
+		group Group[int]
+		calls int
+	)
+
+	for range 3 {
+		_, _, shared := group.Do("key", func() (int, error) {
+			calls++
+			return calls, nil
+		})
+
+		if shared {
+			t.Error("expected sequential calls not to be shared")
+		}
+	}
+
+	if calls != 3 {
+		t.Errorf("got %d calls, want 3", calls)
+	}
+}
+
+func TestDoDifferentKeys(t *testing.T) {
+	var (
+		group   Group[string]
+		running sync.WaitGroup
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+
+	results := make(chan string, 2)
+	for _, key := range []string{"a", "b"} {
+		wg.Add(1)
+		running.Add(1)
+		go func() {
+			defer wg.Done()
+			result, _, _ := group.Do(key, func() (string, error) {
+				running.Done()
+				<-release
+				return key, nil
+			})
+
+			results <- result
+		}()
+	}
+
+	// Both calls are in-flight at the same time, instead of one waiting for
+	// the other
+	running.Wait()
+	close(release)
+	wg.Wait()
+	close(results)
+
+	var got []string
+	for result := range results {
+		got = append(got, result)
+	}
+
+	if len(got) != 2 || got[0] == got[1] {
+		t.Errorf("got %v, want results of both keys", got)
+	}
+}